@@ -1,15 +1,188 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/jedipunkz/fuzz.fish/internal/app"
+	"github.com/jedipunkz/fuzz.fish/internal/config"
 )
 
 func main() {
+	// `fuzz config check` validates the config file.
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:]))
+	}
+
+	// A broken config file must not take history search down with it: only
+	// `fuzz config check` fails on one.
+	if err := loadConfig(config.Path()); err != nil {
+		fmt.Fprintf(os.Stderr, "fuzz: %v; using the default settings\n", err)
+	}
+
+	// `fuzz filter` ranks items without the TUI, for scripts and tests.
+	if len(os.Args) > 1 && os.Args[1] == "filter" {
+		os.Exit(runFilter(os.Args[2:]))
+	}
+
+	// `fuzz secrets` lists or purges the history commands holding secrets.
+	if len(os.Args) > 1 && os.Args[1] == "secrets" {
+		os.Exit(runSecrets(os.Args[2:]))
+	}
+
 	// --query pre-fills the search box (e.g. with the current Fish command line).
 	query := flag.String("query", "", "initial search query")
+	// --output json prints one versioned JSON object instead of the
+	// PREFIX:value records read by conf.d/fuzz.fish.
+	output := flag.String("output", app.OutputText, "result format: text or json")
+	// --stdin picks from piped lines (`some-command | fuzz --stdin`) and
+	// prints the chosen ones.
+	stdin := flag.Bool("stdin", false, "pick from lines read from standard input")
 	flag.Parse()
 
-	app.Run(*query)
+	if *output != app.OutputText && *output != app.OutputJSON {
+		fmt.Fprintf(os.Stderr, "fuzz: unknown --output %q (want text or json)\n", *output)
+		os.Exit(2)
+	}
+
+	if *stdin {
+		if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprintln(os.Stderr, "fuzz: --stdin needs input piped in, e.g. ls | fuzz --stdin")
+			os.Exit(2)
+		}
+	}
+
+	os.Exit(app.Run(app.Options{Query: *query, Output: *output, Stdin: *stdin}))
+}
+
+// loadConfig reads the config file at path and applies it.
+func loadConfig(path string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	if err := app.Configure(cfg); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// runConfig runs the config subcommand and returns its exit status.
+func runConfig(args []string) int {
+	if len(args) == 0 || len(args) > 2 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: fuzz config check [file]")
+		return 2
+	}
+	path := config.Path()
+	if len(args) == 2 {
+		path = args[1]
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && len(args) == 1 {
+		fmt.Printf("%s does not exist; the defaults are used\n", path)
+		return 0
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "fuzz config: %v\n", err)
+		return 1
+	}
+	if err := loadConfig(path); err != nil {
+		fmt.Fprintf(os.Stderr, "fuzz config: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s: ok\n", path)
+	return 0
+}
+
+// runFilter runs the filter subcommand and returns its exit status.
+func runFilter(args []string) int {
+	modes := make([]string, 0, len(app.FilterModes))
+	for name := range app.FilterModes {
+		modes = append(modes, name)
+	}
+	sort.Strings(modes)
+
+	fs := flag.NewFlagSet("fuzz filter", flag.ContinueOnError)
+	var opts app.FilterOptions
+	fs.StringVar(&opts.Mode, "mode", "history", "items to rank: "+strings.Join(modes, ", "))
+	fs.StringVar(&opts.Query, "query", "", "search query, as typed in the TUI")
+	fs.IntVar(&opts.Limit, "limit", 0, "print at most this many results (0 for all)")
+	fs.BoolVar(&opts.Scores, "scores", false, "print each result's ranking score first")
+	fs.BoolVar(&opts.Indexes, "indexes", false, "print the byte offsets of each result's matched characters first")
+	fs.BoolVar(&opts.Print0, "print0", false, "terminate results with NUL instead of newline")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := app.Filter(os.Stdout, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "fuzz filter: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runSecrets runs the secrets subcommand and returns its exit status.
+func runSecrets(args []string) int {
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "usage: fuzz secrets list | fuzz secrets purge [--yes]")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+	fs := flag.NewFlagSet("fuzz secrets "+args[0], flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "purge without asking")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() > 0 || (args[0] == "list" && *yes) {
+		return usage()
+	}
+
+	entries, err := app.SecretHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fuzz secrets: %v\n", err)
+		return 1
+	}
+	switch args[0] {
+	case "list":
+		for _, e := range entries {
+			fmt.Printf("%s\t%d\t%s\n", strings.Join(e.Secrets, ","), e.Typed, strings.ReplaceAll(e.Cmd, "\n", " "))
+		}
+		return 0
+	case "purge":
+	default:
+		return usage()
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No secrets found in the history")
+		return 0
+	}
+	cmds := make([]string, len(entries))
+	typed := 0
+	for i, e := range entries {
+		cmds[i] = e.Cmd
+		typed += e.Typed
+		line := strings.ReplaceAll(e.Cmd, "\n", " ")
+		if e.Typed > 1 {
+			line += fmt.Sprintf(" (%d commands)", e.Typed)
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+	if !*yes {
+		fmt.Fprintf(os.Stderr, "Delete these %d commands from the history? [y/N] ", typed)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.TrimSpace(answer); a != "y" && a != "Y" {
+			return 1
+		}
+	}
+	removed, err := app.DeleteHistory(cmds)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fuzz secrets: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Deleted %d history entries\n", removed)
+	return 0
 }
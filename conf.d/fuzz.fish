@@ -136,6 +136,84 @@ if status is-interactive
     _fuzz_fish_ensure_binary
 end
 
+# Print the editor command, one word per line: $VISUAL, $EDITOR or vi when
+# neither is set.
+function _fuzz_fish_editor
+    if set -q VISUAL; and test -n "$VISUAL"
+        string split -n ' ' -- $VISUAL
+    else if set -q EDITOR; and test -n "$EDITOR"
+        string split -n ' ' -- $EDITOR
+    else
+        echo vi
+    end
+end
+
+# Open files or directories in the editor, in the given order.
+function _fuzz_fish_open
+    set -l editor (_fuzz_fish_editor)
+    $editor $argv
+end
+
+# Open content search hits, given as line:path, in the editor at their lines.
+# Several hits keep their order; editors that cannot open several files at a
+# line each open every file once.
+function _fuzz_fish_goto
+    set -l editor (_fuzz_fish_editor)
+    set -l lines
+    set -l paths
+    for hit in $argv
+        set -l parts (string split -m 1 ':' -- $hit)
+        set -a lines $parts[1]
+        set -a paths $parts[2]
+    end
+
+    # Editors disagree on how to jump to a line
+    switch (basename -- $editor[1])
+        case hx helix
+            set -l targets
+            for i in (seq (count $paths))
+                set -a targets "$paths[$i]:$lines[$i]"
+            end
+            $editor $targets
+        case code code-insiders codium
+            set -l targets
+            for i in (seq (count $paths))
+                set -a targets "$paths[$i]:$lines[$i]"
+            end
+            $editor --goto $targets
+        case emacs emacsclient nano
+            # These take +line before every file
+            set -l args
+            for i in (seq (count $paths))
+                set -a args +$lines[$i] $paths[$i]
+            end
+            $editor $args
+        case vim nvim
+            if test (count $paths) -eq 1
+                $editor +$lines[1] -- $paths[1]
+                return
+            end
+            # Several hits become the quickfix list
+            set -l errorfile (mktemp)
+            for i in (seq (count $paths))
+                printf '%s:%s:fuzz.fish\n' $paths[$i] $lines[$i] >>$errorfile
+            end
+            $editor -q $errorfile
+            rm -f $errorfile
+        case '*'
+            # vi, micro and most others take +line for a single file
+            if test (count $paths) -eq 1
+                $editor +$lines[1] $paths[1]
+                return
+            end
+            set -l unique
+            for path in $paths
+                contains -- $path $unique; or set -a unique $path
+            end
+            $editor $unique
+    end
+end
+
 # History search function
 function fh --description 'Fish History viewer with context (TUI)'
     set -l bin_path (_fuzz_ensure_binary_or_error); or return 1
@@ -146,42 +224,71 @@ function fh --description 'Fish History viewer with context (TUI)'
 
     # Run the TUI binary
     # Redirect stdin/stderr to /dev/tty for TUI interaction,
-    # while capturing stdout for the selected command/branch/file
-    # `string collect` keeps the output as one value: a selected history command
-    # may contain newlines, which command substitution would otherwise split.
-    set -l result ($bin_path --query "$query" </dev/tty 2>/dev/tty | string collect)
-
-    if test -n "$result"
-        if string match -q "CMD:*" -- "$result"
-            # It's a history command, replace command line
-            set -l cmd (string replace "CMD:" "" -- "$result" | string collect)
-            commandline -r -- "$cmd"
-            commandline -f repaint
-        else if string match -q "BRANCH:*" -- "$result"
-            # It's a git branch, switch to it
-            set -l branch (string replace "BRANCH:" "" -- "$result")
-            # Pass the branch as an argument instead of building a shell string:
-            # branch names may contain quotes and semicolons, which a quoted
-            # `fish -c` string would execute.
-            # Let git report why a switch failed (dirty tree, ambiguous remote
-            # branch) instead of silently leaving the shell where it was.
-            if not git switch --quiet -- "$branch" >/dev/null
-                echo "fuzz.fish: could not switch to '$branch'" >&2
+    # while capturing stdout for the selected commands/branches/files.
+    # Every result is a KIND:value record terminated by a NUL byte, so values
+    # may contain newlines (e.g. multi-line history commands).
+    set -l results ($bin_path --query "$query" </dev/tty 2>/dev/tty | string split0)
+    if test (count $results) -eq 0
+        return
+    end
+
+    # All records share the same kind
+    set -l kind (string split -m 1 ':' -- $results[1])[1]
+    set -l values
+    for record in $results
+        set -a values (string replace -- "$kind:" "" "$record" | string collect)
+    end
+
+    switch $kind
+        case CMD
+            # History commands replace the command line, one per line unless
+            # FUZZ_FISH_HISTORY_JOIN sets another separator (e.g. "; ")
+            set -l separator \n
+            if set -q FUZZ_FISH_HISTORY_JOIN
+                set separator $FUZZ_FISH_HISTORY_JOIN
             end
-            # Force repaint to update prompt
-            commandline -f repaint
-        else if string match -q "DIR:*" -- "$result"
+            commandline -r -- (string join -- $separator $values | string collect)
+        case BRANCH
+            if test (count $values) -gt 1
+                # Several branches cannot all be switched to: insert their names
+                commandline -i -- (string escape -- $values | string join ' ')
+            else
+                # Pass the branch as an argument instead of building a shell
+                # string: branch names may contain quotes and semicolons, which
+                # a quoted `fish -c` string would execute.
+                # Let git report why a switch failed (dirty tree, ambiguous
+                # remote branch) instead of silently leaving the shell where it
+                # was.
+                if not git switch --quiet -- "$values[1]" >/dev/null
+                    echo "fuzz.fish: could not switch to '$values[1]'" >&2
+                end
+            end
+        case DIR
             # It's a directory, cd into it
-            set -l dir_path (string replace "DIR:" "" -- "$result" | string collect)
-            cd "$dir_path"
-            commandline -f repaint
-        else if string match -q "FILE:*" -- "$result"
-            # It's a file, insert into command line
-            set -l file_path (string replace "FILE:" "" -- "$result" | string collect)
-            commandline -i -- "$file_path"
-            commandline -f repaint
-        end
+            cd "$values[1]"
+        case COMMIT
+            # Commit hashes are inserted into the command line
+            commandline -i -- (string join ' ' -- $values)
+        case STASH_APPLY STASH_POP STASH_DROP
+            # Stash entries are applied, popped or dropped in the given order
+            set -l action (string lower -- (string replace STASH_ "" -- $kind))
+            for ref in $values
+                if not git stash $action --quiet "$ref"
+                    echo "fuzz.fish: could not $action '$ref'" >&2
+                end
+            end
+        case OPEN
+            # Paths to open in the editor
+            _fuzz_fish_open $values
+        case GOTO
+            # Content search hits to open at their line, as line:path
+            _fuzz_fish_goto $values
+        case FILE
+            # File paths are inserted fish-quoted and space-separated
+            commandline -i -- (string escape -- $values | string join ' ')
     end
+    # Force repaint to update prompt
+    commandline -f repaint
 end
 
 # Set up Ctrl+R key bindings for history/git/files unified search
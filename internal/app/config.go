@@ -0,0 +1,33 @@
+package app
+
+import (
+	"github.com/jedipunkz/fuzz.fish/internal/config"
+	"github.com/jedipunkz/fuzz.fish/internal/ui"
+)
+
+// settings are the settings in use, replaced by Configure
+var settings = config.Default()
+
+// Configure applies cfg, e.g. as loaded by config.Load. It fails when cfg
+// binds keys to unknown actions, chooses an unknown theme or has secret rules
+// that do not compile, in which case nothing is changed.
+func Configure(cfg config.Config) error {
+	bindings, err := newKeyBindings(cfg.Keys)
+	if err != nil {
+		return err
+	}
+	theme, err := cfg.ResolveTheme()
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Secrets.Redactor(); err != nil {
+		return err
+	}
+	settings = cfg
+	keyBindings = bindings
+	ui.HistoryContextLinesBefore = cfg.Preview.HistoryContextBefore
+	ui.HistoryContextLinesAfter = cfg.Preview.HistoryContextAfter
+	ui.SetTheme(theme)
+	buildStyles()
+	return nil
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jedipunkz/fuzz.fish/internal/config"
+)
+
+// actionName returns the name of the action key triggers in mode, or "".
+func actionName(mode SearchMode, key string) string {
+	if a := actionFor(mode, key); a != nil {
+		return a.name
+	}
+	return ""
+}
+
+func TestActionFor_ModeSpecificActionsComeFirst(t *testing.T) {
+	tests := []struct {
+		mode SearchMode
+		key  string
+		want string
+	}{
+		{ModeHistory, "ctrl+s", "mode.files"},
+		{ModeFiles, "ctrl+s", "files.toggle-all"},
+		{ModeHistory, "ctrl+r", "history.scope"},
+		{ModeFiles, "ctrl+r", "mode.history"},
+		{ModeGitBranch, "ctrl+g", "branch.pull"},
+		{ModeStash, "alt+enter", "stash.pop"},
+		{ModeFiles, "alt+enter", "open"},
+		{ModeHistory, "alt+enter", ""},
+		{ModeHistory, "ctrl+x", "delete"},
+		{ModeCommit, "ctrl+x", ""},
+		{ModeStdin, "ctrl+r", ""},
+		{ModeStdin, "enter", "select"},
+	}
+	for _, tt := range tests {
+		if got := actionName(tt.mode, tt.key); got != tt.want {
+			t.Errorf("actionFor(%v, %q) = %q, want %q", tt.mode, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestConfigure_RebindsKeys(t *testing.T) {
+	t.Cleanup(func() { _ = Configure(config.Default()) })
+
+	cfg := config.Default()
+	cfg.Keys = config.Keys{
+		"mode.files":       {"ctrl+e"},
+		"files.toggle-all": {},
+		"quit":             {"ctrl+q"},
+		"select":           {"enter", "ctrl+r"},
+	}
+	if err := Configure(cfg); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	tests := []struct {
+		mode SearchMode
+		key  string
+		want string
+	}{
+		{ModeHistory, "ctrl+e", "mode.files"},
+		{ModeHistory, "ctrl+s", ""}, // the default key no longer does anything
+		{ModeFiles, "ctrl+s", ""},   // unbound
+		{ModeHistory, "ctrl+q", "quit"},
+		{ModeHistory, "esc", ""},
+		{ModeHistory, "ctrl+r", "select"}, // taken from history.scope and mode.history
+		{ModeHistory, "ctrl+g", "mode.branch"},
+	}
+	for _, tt := range tests {
+		if got := actionName(tt.mode, tt.key); got != tt.want {
+			t.Errorf("actionFor(%v, %q) = %q, want %q", tt.mode, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestConfigure_RejectsBadBindings(t *testing.T) {
+	t.Cleanup(func() { _ = Configure(config.Default()) })
+
+	tests := []struct {
+		keys config.Keys
+		want string
+	}{
+		{config.Keys{"mode.nope": {"ctrl+e"}}, "keys.mode.nope: unknown action"},
+		{config.Keys{"copy": {""}}, "keys.copy: empty key"},
+		{config.Keys{"copy": {"ctrl+e"}, "delete": {"ctrl+e"}}, "ctrl+e is already bound to delete"},
+	}
+	for _, tt := range tests {
+		cfg := config.Default()
+		cfg.Keys = tt.keys
+		err := Configure(cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Configure(%v) error = %v, want %q", tt.keys, err, tt.want)
+		}
+	}
+	// A rejected config changes nothing.
+	if got := actionName(ModeHistory, "ctrl+e"); got != "" {
+		t.Errorf("ctrl+e triggers %q after rejected configs", got)
+	}
+
+	// Actions that are never available in the same mode can share a key.
+	cfg := config.Default()
+	cfg.Keys = config.Keys{"stash.pop": {"alt+o"}, "open": {"alt+o"}}
+	if err := Configure(cfg); err != nil {
+		t.Errorf("Configure(%v) error = %v", cfg.Keys, err)
+	}
+}
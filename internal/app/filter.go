@@ -1,9 +1,13 @@
 package app
 
 import (
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/jedipunkz/fuzz.fish/internal/files"
 	"github.com/jedipunkz/fuzz.fish/internal/git"
 	"github.com/jedipunkz/fuzz.fish/internal/history"
 	"github.com/jedipunkz/fuzz.fish/internal/scoring"
@@ -17,19 +21,29 @@ func (m *model) loadItemsForMode() {
 		// History: entries are Newest -> Oldest
 		// We want Newest at Bottom.
 		// Item[0] should be Oldest, Item[N] should be Newest.
-		n := len(m.historyEntries)
-		if cap(m.allItems) >= n {
-			m.allItems = m.allItems[:n]
-		} else {
-			m.allItems = make([]Item, n)
-		}
-		for i := range m.historyEntries {
-			e := m.historyEntries[n-1-i]
-			m.allItems[i] = Item{
+		// A scope leaves out the commands run elsewhere. Where a command
+		// was run is worked out once here, not for every match of a query.
+		m.allItems = m.allItems[:0]
+		for i := len(m.historyEntries) - 1; i >= 0; i-- {
+			e := m.historyEntries[i]
+			inDir := m.cwd != "" && e.InDir(m.cwd)
+			switch m.historyScope {
+			case scopeDir:
+				if !inDir {
+					continue
+				}
+			case scopeRepo:
+				// The current directory is in the working tree.
+				if !inDir && !e.InDir(m.gitRoot) {
+					continue
+				}
+			}
+			m.allItems = append(m.allItems, Item{
 				Text:     e.Cmd,
-				Index:    n - 1 - i,
+				Index:    i,
 				Original: e,
-			}
+				InDir:    inDir,
+			})
 		}
 	case ModeGitBranch:
 		// Git: branches are collected.
@@ -51,8 +65,8 @@ func (m *model) loadItemsForMode() {
 			}
 		}
 	case ModeFiles:
-		// Files: entries are in directory order
-		// We reverse them to put first item at bottom.
+		// Files: entries are in directory order, listed reversed to put
+		// the first item at the bottom.
 		n := len(m.fileEntries)
 		if cap(m.allItems) >= n {
 			m.allItems = m.allItems[:n]
@@ -60,13 +74,7 @@ func (m *model) loadItemsForMode() {
 			m.allItems = make([]Item, n)
 		}
 		for i := range m.fileEntries {
-			f := m.fileEntries[n-1-i]
-			m.allItems[i] = Item{
-				Text:     f.Path,
-				Index:    n - 1 - i,
-				Original: f,
-				IsDir:    f.IsDir,
-			}
+			m.allItems[i] = fileItem(i, m.fileEntries[i])
 		}
 	case ModeWorktree:
 		// Worktrees: keep listing order, reverse so first item sits at bottom.
@@ -89,6 +97,92 @@ func (m *model) loadItemsForMode() {
 				IsDir:      true,
 			}
 		}
+	case ModeCommit:
+		// Commits: git log order is newest first, reverse so it sits at bottom.
+		n := len(m.commits)
+		if cap(m.allItems) >= n {
+			m.allItems = m.allItems[:n]
+		} else {
+			m.allItems = make([]Item, n)
+		}
+		for i := range m.commits {
+			c := m.commits[n-1-i]
+			text := c.ShortHash + " " + c.Subject
+			m.allItems[i] = Item{
+				Text: text,
+				// Author is searchable too; renderItem shows the same suffix.
+				SearchText: text + " (" + c.Author + ")",
+				Index:      n - 1 - i,
+				Original:   c,
+			}
+		}
+	case ModeStash:
+		// Stashes: stash@{0} is newest, reverse so it sits at bottom.
+		n := len(m.stashes)
+		if cap(m.allItems) >= n {
+			m.allItems = m.allItems[:n]
+		} else {
+			m.allItems = make([]Item, n)
+		}
+		for i := range m.stashes {
+			st := m.stashes[n-1-i]
+			text := st.Ref + " " + st.Message
+			m.allItems[i] = Item{
+				Text: text,
+				// Branch is searchable too; renderItem shows the same suffix.
+				SearchText: text + " [" + st.Branch + "]",
+				Index:      n - 1 - i,
+				Original:   st,
+			}
+		}
+	case ModeStatus:
+		// Status: git lists files by path, reverse so the first sits at bottom.
+		cwd, _ := os.Getwd()
+		statusFiles := m.gitStatus.Files
+		n := len(statusFiles)
+		if cap(m.allItems) >= n {
+			m.allItems = m.allItems[:n]
+		} else {
+			m.allItems = make([]Item, n)
+		}
+		for i := range statusFiles {
+			f := statusFiles[n-1-i]
+			// Show paths relative to the current directory, like file search,
+			// so the selection can be used from the prompt as is.
+			path := filepath.Join(f.Root, f.Path)
+			if rel, err := filepath.Rel(cwd, path); err == nil {
+				path = rel
+			}
+			m.allItems[i] = Item{
+				Text:     path,
+				Index:    n - 1 - i,
+				Original: f,
+			}
+		}
+	case ModeGrep:
+		// Matches: in the order found, listed reversed so the first sits at
+		// the bottom.
+		n := len(m.grepMatches)
+		if cap(m.allItems) >= n {
+			m.allItems = m.allItems[:n]
+		} else {
+			m.allItems = make([]Item, n)
+		}
+		for i := range m.grepMatches {
+			m.allItems[i] = grepItem(i, m.grepMatches[i])
+		}
+	case ModeStdin:
+		// Lines: in input order, listed reversed so the first sits at the
+		// bottom.
+		n := len(m.stdinLines)
+		if cap(m.allItems) >= n {
+			m.allItems = m.allItems[:n]
+		} else {
+			m.allItems = make([]Item, n)
+		}
+		for i := range m.stdinLines {
+			m.allItems[i] = stdinItem(i, m.stdinLines[i])
+		}
 	default:
 		m.allItems = m.allItems[:0]
 	}
@@ -96,12 +190,75 @@ func (m *model) loadItemsForMode() {
 	// Pre-build search strings to avoid per-keystroke allocation
 	m.allItemsStr = make([]string, len(m.allItems))
 	for i, item := range m.allItems {
-		if item.SearchText != "" {
-			m.allItemsStr[i] = item.SearchText
-		} else {
-			m.allItemsStr[i] = item.Text
-		}
+		m.allItemsStr[i] = m.searchString(item)
+	}
+}
+
+// fileItem returns the item of files mode for f, fileEntries[i].
+func fileItem(i int, f files.Entry) Item {
+	return Item{
+		Text:     f.Path,
+		Index:    i,
+		Original: f,
+		IsDir:    f.IsDir,
+	}
+}
+
+// grepItem returns the item of content search mode for g, grepMatches[i].
+func grepItem(i int, g files.Match) Item {
+	// Indentation only takes up room in the list. Tabs render wider
+	// than one cell, so they become spaces, which keeps byte offsets.
+	line := strings.TrimLeft(g.Text, " \t")
+	prefix := g.Path + ":" + strconv.Itoa(g.Line) + ": "
+	text := prefix + strings.ReplaceAll(line, "\t", " ")
+	// The match is highlighted like a fuzzy match of the query.
+	start := len(prefix) + g.Col - (len(g.Text) - len(line))
+	var matched []int
+	for b := max(start, len(prefix)); b < start+g.Len; b++ {
+		matched = append(matched, b)
+	}
+	return Item{
+		Text:           text,
+		Index:          i,
+		Original:       g,
+		MatchedIndexes: matched,
+	}
+}
+
+// stdinItem returns the item of stdin mode for line, stdinLines[i].
+func stdinItem(i int, line string) Item {
+	return Item{
+		Text:     line,
+		Index:    i,
+		Original: line,
+	}
+}
+
+// searchString returns the string item is matched against, which its match
+// indexes refer to.
+func (m *model) searchString(item Item) string {
+	if item.SearchText != "" {
+		return item.SearchText
 	}
+	return item.Text
+}
+
+// itemSignals returns the non-match ranking signals of an item: the timestamp
+// for recency, the frequency for frecency (history only), whether it is the
+// current branch and whether it was run in the current directory (history
+// only).
+func (m *model) itemSignals(item Item) (timestamp int64, frequency int, isCurrent, inDir bool) {
+	switch o := item.Original.(type) {
+	case history.Entry:
+		return o.When, o.Count, false, item.InDir
+	case git.Branch:
+		return o.CommitTimestamp, 0, o.IsCurrent, false
+	case git.Commit:
+		return o.Timestamp, 0, false, false
+	case git.Stash:
+		return o.Timestamp, 0, false, false
+	}
+	return 0, 0, false, false
 }
 
 // sortDedupe returns the indexes sorted ascending with duplicates removed.
@@ -121,134 +278,194 @@ func sortDedupe(ids []int) []int {
 	return out
 }
 
-// updateFilter updates the filtered items based on the query
+// updateFilter updates the filtered items based on the query and moves the
+// cursor to the best match
 func (m *model) updateFilter(query string) {
-	if query == "" {
-		// Return all items (which are already in display order)
+	m.filterItems(query)
+	m.resetCursor()
+	m.updatePreview()
+}
+
+// appendsItems reports whether allItems are kept in the order of their
+// source, which is listed reversed, rather than in list order: the entries
+// of these modes stream in, and later batches are appended to allItems.
+func (m *model) appendsItems() bool {
+	return m.mode == ModeFiles || m.mode == ModeGrep || m.mode == ModeStdin
+}
+
+// listPos returns the position in allItems, of n items, of the item at
+// position i in list order.
+func (m *model) listPos(i, n int) int {
+	if m.appendsItems() {
+		return n - 1 - i
+	}
+	return i
+}
+
+// filterItems sets m.filtered to the items matching query, ranked with the
+// best match last
+func (m *model) filterItems(query string) {
+	m.filtered = m.rankItems(query, m.allItems, m.allItemsStr, m.filtered)
+}
+
+// rankItems returns the items matching query, ranked with the best match last
+// and ties in list order. strs are their pre-built search strings. The result
+// reuses buf when the query matches everything.
+func (m *model) rankItems(query string, items []Item, strs []string, buf []Item) []Item {
+	// Content search items are the hits of the query already.
+	if m.mode == ModeGrep {
+		query = ""
+	}
+	tokens := strings.Fields(query)
+	if len(tokens) == 0 {
+		// Return all items in list order
 		// Reuse existing slice if capacity allows
-		if cap(m.filtered) >= len(m.allItems) {
-			m.filtered = m.filtered[:len(m.allItems)]
+		if cap(buf) >= len(items) {
+			buf = buf[:len(items)]
 		} else {
-			m.filtered = make([]Item, len(m.allItems))
-		}
-		copy(m.filtered, m.allItems)
-	} else {
-		// Fuzzy search using pre-built search strings (avoids per-keystroke allocation)
-		tokens := strings.Fields(query)
-		if len(tokens) > 0 && queryHasGlob(query) {
-			// Glob matching: a '*' in the query switches to literal, ordered
-			// substring matching (e.g. "nvim *.go") instead of fuzzy scatter.
-			m.globFilter(tokens)
-		} else if len(tokens) > 0 {
-			matches := fuzzy.Find(tokens[0], m.allItemsStr)
-
-			// Aggregate per-item match quality across every token. Multi-token
-			// queries ("git pull") AND each token, but the combined score must
-			// reflect all tokens: summed fuzzy score and the union of matched
-			// indexes. Keeping only the first token's data hides where later
-			// tokens matched, so a contiguous match ("git pull origin main")
-			// could not be distinguished from a scattered one
-			// ("git config pull.rebase true").
-			aggScore := make(map[int]int, len(matches))
-			aggIdx := make(map[int][]int, len(matches))
-			for _, mat := range matches {
-				aggScore[mat.Index] = mat.Score
-				aggIdx[mat.Index] = append([]int(nil), mat.MatchedIndexes...)
-			}
+			buf = make([]Item, len(items))
+		}
+		for i := range buf {
+			buf[i] = items[m.listPos(i, len(items))]
+		}
+		return buf
+	}
+	if queryHasGlob(query) {
+		// Glob matching: a '*' in the query switches to literal, ordered
+		// substring matching (e.g. "nvim *.go") instead of fuzzy scatter.
+		return m.globFilter(tokens, items, strs)
+	}
 
-			for _, token := range tokens[1:] {
-				if len(matches) == 0 {
-					break
-				}
-				subset := make([]string, len(matches))
-				for i, mat := range matches {
-					subset[i] = m.allItemsStr[mat.Index]
-				}
-				subMatches := fuzzy.Find(token, subset)
-				newMatches := make(fuzzy.Matches, len(subMatches))
-				for i, sm := range subMatches {
-					orig := matches[sm.Index]
-					aggScore[orig.Index] += sm.Score
-					aggIdx[orig.Index] = append(aggIdx[orig.Index], sm.MatchedIndexes...)
-					newMatches[i] = orig
-				}
-				matches = newMatches
-			}
+	// Fuzzy search using pre-built search strings (avoids per-keystroke allocation)
+	matches := fuzzy.Find(tokens[0], strs)
 
-			// Sort and dedupe each item's matched indexes so gap/boundary
-			// bonuses and highlighting see the full, ordered match set.
-			for idx, ids := range aggIdx {
-				aggIdx[idx] = sortDedupe(ids)
-			}
+	// Aggregate per-item match quality across every token. Multi-token
+	// queries ("git pull") AND each token, but the combined score must
+	// reflect all tokens: summed fuzzy score and the union of matched
+	// indexes. Keeping only the first token's data hides where later
+	// tokens matched, so a contiguous match ("git pull origin main")
+	// could not be distinguished from a scattered one
+	// ("git config pull.rebase true").
+	aggScore := make(map[int]int, len(matches))
+	aggIdx := make(map[int][]int, len(matches))
+	for _, mat := range matches {
+		aggScore[mat.Index] = mat.Score
+		aggIdx[mat.Index] = append([]int(nil), mat.MatchedIndexes...)
+	}
 
-			// Pre-calculate scores for all matches (O(n) instead of O(n log n) in comparator)
-			config := scoring.DefaultConfig()
-			now := scoring.CurrentTimestamp()
-			scores := make([]float64, len(matches))
-			for i, mat := range matches {
-				item := m.allItems[mat.Index]
-				var timestamp int64
-				var frequency int
-				var isCurrent bool
-				switch m.mode {
-				case ModeHistory:
-					if entry, ok := item.Original.(history.Entry); ok {
-						timestamp = entry.When
-						frequency = entry.Count
-					}
-				case ModeGitBranch:
-					if branch, ok := item.Original.(git.Branch); ok {
-						timestamp = branch.CommitTimestamp
-						isCurrent = branch.IsCurrent
-					}
-				}
-				// Score against the string the indexes were matched in, not the
-				// display text: they differ in worktree mode, where the branch
-				// suffix is part of the search string.
-				scores[i] = config.ItemScore(m.allItemsStr[mat.Index], aggScore[mat.Index], aggIdx[mat.Index], timestamp, frequency, isCurrent, now)
-			}
+	for _, token := range tokens[1:] {
+		if len(matches) == 0 {
+			break
+		}
+		subset := make([]string, len(matches))
+		for i, mat := range matches {
+			subset[i] = strs[mat.Index]
+		}
+		subMatches := fuzzy.Find(token, subset)
+		newMatches := make(fuzzy.Matches, len(subMatches))
+		for i, sm := range subMatches {
+			orig := matches[sm.Index]
+			aggScore[orig.Index] += sm.Score
+			aggIdx[orig.Index] = append(aggIdx[orig.Index], sm.MatchedIndexes...)
+			newMatches[i] = orig
+		}
+		matches = newMatches
+	}
 
-			// Create index array for sorting (scores array must stay aligned with original matches)
-			indices := make([]int, len(matches))
-			for i := range indices {
-				indices[i] = i
-			}
+	// Sort and dedupe each item's matched indexes so gap/boundary
+	// bonuses and highlighting see the full, ordered match set.
+	for idx, ids := range aggIdx {
+		aggIdx[idx] = sortDedupe(ids)
+	}
 
-			// Sort indices by pre-calculated scores
-			// Higher combined score should appear at bottom (higher priority)
-			// So we sort ascending: lower scores first, higher scores last (at bottom)
-			sort.SliceStable(indices, func(i, j int) bool {
-				return scores[indices[i]] < scores[indices[j]]
-			})
+	// Pre-calculate scores for all matches (O(n) instead of O(n log n) in comparator)
+	config := settings.Scoring
+	now := scoring.CurrentTimestamp()
+	scores := make([]float64, len(matches))
+	for i, mat := range matches {
+		timestamp, frequency, isCurrent, inDir := m.itemSignals(items[mat.Index])
+		// Score against the string the indexes were matched in, not the
+		// display text: they differ in worktree mode, where the branch
+		// suffix is part of the search string.
+		scores[i] = config.ItemScore(strs[mat.Index], aggScore[mat.Index], aggIdx[mat.Index], timestamp, frequency, isCurrent, inDir, now)
+	}
 
-			// Build filtered list using sorted indices
-			m.filtered = make([]Item, len(indices))
-			for rank, idx := range indices {
-				mat := matches[idx]
-				item := m.allItems[mat.Index]
-				item.MatchedIndexes = aggIdx[mat.Index]
-				m.filtered[rank] = item
-			}
-		} else {
-			// Query is just whitespace, treat as empty
-			if cap(m.filtered) >= len(m.allItems) {
-				m.filtered = m.filtered[:len(m.allItems)]
-			} else {
-				m.filtered = make([]Item, len(m.allItems))
-			}
-			copy(m.filtered, m.allItems)
-		}
+	// Create index array for sorting (scores array must stay aligned with
+	// original matches). fuzzy.Find orders matches by its own score, so they
+	// are put back in list order first, which ties keep.
+	indices := make([]int, len(matches))
+	for i := range indices {
+		indices[i] = i
 	}
+	sort.Slice(indices, func(i, j int) bool {
+		return m.listPos(matches[indices[i]].Index, len(items)) < m.listPos(matches[indices[j]].Index, len(items))
+	})
+
+	// Sort indices by pre-calculated scores
+	// Higher combined score should appear at bottom (higher priority)
+	// So we sort ascending: lower scores first, higher scores last (at bottom)
+	sort.SliceStable(indices, func(i, j int) bool {
+		return scores[indices[i]] < scores[indices[j]]
+	})
+
+	// Build filtered list using sorted indices
+	filtered := make([]Item, len(indices))
+	for rank, idx := range indices {
+		mat := matches[idx]
+		item := items[mat.Index]
+		item.MatchedIndexes = aggIdx[mat.Index]
+		item.Score = scores[idx]
+		filtered[rank] = item
+	}
+	return filtered
+}
 
-	if len(m.filtered) > 0 {
-		m.cursor = len(m.filtered) - 1
-		m.offset = m.cursor - m.mainHeight + 1
-		if m.offset < 0 {
-			m.offset = 0
+// appendItems adds the items of source entries that arrived after the list
+// was built (streamed files, content search hits or stdin lines) without
+// building it again: only the new items are matched against the query, and
+// merged into the ranking. Like loadItemsForMode lists them, later entries go
+// before earlier ones. The cursor stays on the selected item.
+func (m *model) appendItems(items []Item) {
+	if len(items) == 0 {
+		return
+	}
+	strs := make([]string, len(items))
+	for i, item := range items {
+		strs[i] = m.searchString(item)
+	}
+	m.allItems = append(m.allItems, items...)
+	m.allItemsStr = append(m.allItemsStr, strs...)
+
+	ranked := m.rankItems(m.input.Value(), items, strs, nil)
+	if len(m.filtered) == 0 {
+		m.filtered = ranked
+		m.resetCursor()
+		m.updatePreview()
+		return
+	}
+
+	// Both lists are ranked with ties in list order, where the new items come
+	// first.
+	merged := make([]Item, 0, len(m.filtered)+len(ranked))
+	cursor, offset := m.cursor, m.offset
+	i, j := 0, 0
+	for i < len(m.filtered) || j < len(ranked) {
+		if j < len(ranked) && (i == len(m.filtered) || ranked[j].Score <= m.filtered[i].Score) {
+			if i <= m.cursor {
+				cursor++
+			}
+			if i <= m.offset {
+				offset++
+			}
+			merged = append(merged, ranked[j])
+			j++
+			continue
 		}
-	} else {
-		m.cursor = 0
-		m.offset = 0
+		merged = append(merged, m.filtered[i])
+		i++
 	}
+	m.filtered = merged
+	m.cursor, m.offset = cursor, offset
+	m.validateCursor()
 	m.updatePreview()
 }
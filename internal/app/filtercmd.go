@@ -0,0 +1,117 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jedipunkz/fuzz.fish/internal/git"
+)
+
+// FilterModes are the modes the filter subcommand accepts, by name
+var FilterModes = map[string]SearchMode{
+	"history":   ModeHistory,
+	"files":     ModeFiles,
+	"branches":  ModeGitBranch,
+	"worktrees": ModeWorktree,
+	"commits":   ModeCommit,
+	"stashes":   ModeStash,
+	"status":    ModeStatus,
+}
+
+// FilterOptions configure a non-interactive filter run
+type FilterOptions struct {
+	Mode    string // A key of FilterModes
+	Query   string
+	Limit   int  // Print at most Limit results; 0 prints all
+	Scores  bool // Prefix each result with its ranking score
+	Indexes bool // Prefix each result with the byte offsets of its matched characters
+	Print0  bool // Terminate results with NUL instead of newline (history commands may span lines)
+}
+
+// Filter loads the items of a mode and ranks them against the query exactly
+// like the TUI does, without a terminal. Results are written to w best match
+// first, one per line, as the value enter would give (e.g. a commit's full
+// hash); secrets in history commands stay masked. Scores and match indexes,
+// when requested, come first in tab-separated columns; the indexes are byte
+// offsets into the text matched against, which may hold more than the value.
+func Filter(w io.Writer, opts FilterOptions) error {
+	mode, ok := FilterModes[opts.Mode]
+	if !ok {
+		return fmt.Errorf("unknown mode %q", opts.Mode)
+	}
+
+	m := model{mode: mode}
+	if err := m.loadSync(); err != nil {
+		return err
+	}
+	m.loadItemsForMode()
+	m.filterItems(opts.Query)
+
+	bw := bufio.NewWriter(w)
+	terminator := "\n"
+	if opts.Print0 {
+		terminator = "\x00"
+	}
+	count := 0
+	// The list shows the best match at the bottom; scripts want it first.
+	for i := len(m.filtered) - 1; i >= 0; i-- {
+		if opts.Limit > 0 && count == opts.Limit {
+			break
+		}
+		count++
+		item := m.filtered[i]
+		if opts.Scores {
+			_, _ = fmt.Fprintf(bw, "%.4f\t", item.Score)
+		}
+		if opts.Indexes {
+			idx := make([]string, len(item.MatchedIndexes))
+			for j, b := range item.MatchedIndexes {
+				idx[j] = strconv.Itoa(b)
+			}
+			_, _ = bw.WriteString(strings.Join(idx, ",") + "\t")
+		}
+		value, _ := m.choiceValue(item)
+		_, _ = bw.WriteString(value + terminator)
+	}
+	return bw.Flush()
+}
+
+// loadSync loads the current mode's data the way the async load commands do,
+// waiting for it, including the details the TUI fills in afterwards.
+func (m *model) loadSync() error {
+	r := git.NewRepository(".")
+	var err error
+	switch m.mode {
+	case ModeHistory:
+		m.historyEntries = newHistoryParser().Parse()
+		m.cwd, m.gitRoot = historyDirs()
+	case ModeGitBranch:
+		if m.gitBranches, err = r.Branches(); err != nil {
+			return err
+		}
+		// Without commit times branches would not be ranked by recency.
+		if loaded, err := r.LoadDetails(m.gitBranches); err == nil {
+			m.gitBranches = loaded
+		}
+	case ModeFiles:
+		c, err := newFileCollector(false)
+		if err != nil {
+			return err
+		}
+		// Collect lists the files in the order the TUI streams them, which
+		// ties are ranked in.
+		m.fileEntries = c.Collect()
+	case ModeWorktree:
+		m.worktrees, err = r.Worktrees()
+	case ModeCommit:
+		m.commits, err = r.Commits(false)
+	case ModeStash:
+		m.stashes, err = r.Stashes()
+	case ModeStatus:
+		m.gitStatus, err = r.Status()
+	}
+	return err
+}
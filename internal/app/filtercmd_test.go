@@ -0,0 +1,75 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilter_RanksFilesBestFirst(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"internal/app/update.go", "internal/app/view.go", "README.md"} {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(root)
+
+	var out strings.Builder
+	if err := Filter(&out, FilterOptions{Mode: "files", Query: "update", Indexes: true}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) == 0 {
+		t.Fatal("Filter() printed nothing")
+	}
+	want := "13,14,15,16,17,18\t" + filepath.Join("internal", "app", "update.go")
+	if lines[0] != want {
+		t.Errorf("best match = %q, want %q", lines[0], want)
+	}
+
+	out.Reset()
+	if err := Filter(&out, FilterOptions{Mode: "files", Limit: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "\n"); n != 2 {
+		t.Errorf("Filter(Limit: 2) printed %d results, want 2", n)
+	}
+}
+
+func TestFilter_UnknownMode(t *testing.T) {
+	if err := Filter(&strings.Builder{}, FilterOptions{Mode: "nope"}); err == nil {
+		t.Error("Filter() accepted an unknown mode")
+	}
+}
+
+func TestFilter_TiesFollowTheWalkOrder(t *testing.T) {
+	root := t.TempDir()
+	// Without a query every entry ties, so they are listed as walked, like
+	// the TUI lists them.
+	for _, p := range []string{"b/deep/x/notes.txt", "a/deep/x/notes.txt", "notes.txt"} {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(root)
+
+	var out strings.Builder
+	if err := Filter(&out, FilterOptions{Mode: "files"}); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Fields(filepath.ToSlash(out.String()))
+	want := []string{"a", "b", "notes.txt", "a/deep", "b/deep", "a/deep/x", "b/deep/x", "a/deep/x/notes.txt", "b/deep/x/notes.txt"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Filter() = %q, want the walk order %q", got, want)
+	}
+}
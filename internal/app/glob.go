@@ -4,8 +4,6 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/jedipunkz/fuzz.fish/internal/git"
-	"github.com/jedipunkz/fuzz.fish/internal/history"
 	"github.com/jedipunkz/fuzz.fish/internal/scoring"
 )
 
@@ -46,17 +44,18 @@ func globMatch(token, text string) (matched []int, ok bool) {
 	return matched, true
 }
 
-// globFilter populates m.filtered using glob matching. Every token must match
+// globFilter returns the items matching tokens with glob matching, ranked
+// like rankItems does; strs are their search strings. Every token must match
 // (AND); the union of matched indexes feeds the same scoring and highlighting
 // pipeline as fuzzy matching, so frecency and match-quality ordering behave
 // consistently across both search modes.
-func (m *model) globFilter(tokens []string) {
+func (m *model) globFilter(tokens []string, items []Item, strs []string) []Item {
 	lowerTokens := make([]string, len(tokens))
 	for i, t := range tokens {
 		lowerTokens[i] = strings.ToLower(t)
 	}
 
-	config := scoring.DefaultConfig()
+	config := settings.Scoring
 	now := scoring.CurrentTimestamp()
 
 	type hit struct {
@@ -64,10 +63,12 @@ func (m *model) globFilter(tokens []string) {
 		idx     []int
 		score   float64
 	}
-	hits := make([]hit, 0, len(m.allItems))
+	hits := make([]hit, 0, len(items))
 
-	for i := range m.allItems {
-		text := strings.ToLower(m.allItemsStr[i])
+	for k := range items {
+		// Hits are found in list order, which ties keep.
+		i := m.listPos(k, len(items))
+		text := strings.ToLower(strs[i])
 		var idx []int
 		ok := true
 		for _, token := range lowerTokens {
@@ -83,28 +84,13 @@ func (m *model) globFilter(tokens []string) {
 		}
 		idx = sortDedupe(idx)
 
-		item := m.allItems[i]
-		var timestamp int64
-		var frequency int
-		var isCurrent bool
-		switch m.mode {
-		case ModeHistory:
-			if entry, ok := item.Original.(history.Entry); ok {
-				timestamp = entry.When
-				frequency = entry.Count
-			}
-		case ModeGitBranch:
-			if branch, ok := item.Original.(git.Branch); ok {
-				timestamp = branch.CommitTimestamp
-				isCurrent = branch.IsCurrent
-			}
-		}
+		timestamp, frequency, isCurrent, inDir := m.itemSignals(items[i])
 		// Glob matches have no fuzzy score to pass through: matchedLen is not on
 		// the same scale as one, and using it would shift the balance between
 		// match quality and frecency compared with the fuzzy path. MatchBonus
 		// already rewards contiguous, boundary-aligned matches, so it carries
 		// the match quality alone here.
-		score := config.ItemScore(m.allItemsStr[i], 0, idx, timestamp, frequency, isCurrent, now)
+		score := config.ItemScore(strs[i], 0, idx, timestamp, frequency, isCurrent, inDir, now)
 		hits = append(hits, hit{itemIdx: i, idx: idx, score: score})
 	}
 
@@ -113,10 +99,12 @@ func (m *model) globFilter(tokens []string) {
 		return hits[i].score < hits[j].score
 	})
 
-	m.filtered = make([]Item, len(hits))
+	filtered := make([]Item, len(hits))
 	for rank, h := range hits {
-		item := m.allItems[h.itemIdx]
+		item := items[h.itemIdx]
 		item.MatchedIndexes = h.idx
-		m.filtered[rank] = item
+		item.Score = h.score
+		filtered[rank] = item
 	}
+	return filtered
 }
@@ -0,0 +1,463 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/atotto/clipboard"
+	"github.com/jedipunkz/fuzz.fish/internal/config"
+	"github.com/jedipunkz/fuzz.fish/internal/git"
+	"github.com/jedipunkz/fuzz.fish/internal/ui"
+)
+
+// action is something keys can be bound to. run returns false when the key
+// did nothing, which passes it on to the search box (e.g. → in the middle of
+// the query moves the input cursor).
+type action struct {
+	name  string
+	keys  []string     // Default keys
+	modes []SearchMode // Modes the action is available in; nil for all of them
+	help  string
+	run   func(m *model) (tea.Cmd, bool)
+}
+
+// browseModes are the modes the mode keys switch between, which is all but
+// ModeStdin.
+var browseModes = []SearchMode{ModeHistory, ModeGitBranch, ModeFiles, ModeWorktree, ModeCommit, ModeStash, ModeStatus, ModeGrep}
+
+// actions are the actions keys can be bound to. A key bound to several
+// actions available in a mode triggers the first of them, so mode-specific
+// actions come before the general ones they override (e.g. ctrl+s shows
+// ignored files in files mode and switches to it elsewhere).
+var actions = []action{
+	{
+		name: "branch.pull", keys: []string{"ctrl+g"}, modes: []SearchMode{ModeGitBranch},
+		help: "Pull the current branch",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) > 0 && m.filtered[m.cursor].IsCurrent {
+				branch := m.filtered[m.cursor].Original.(git.Branch)
+				m.choices = []string{branch.Name}
+				m.fetchBranch = true
+				m.quitting = true
+				return tea.Quit, true
+			}
+			m.statusMsg = "⚠ Select current branch to pull"
+			return nil, true
+		},
+	},
+	{
+		name: "branch.new", keys: []string{"alt+n"}, modes: []SearchMode{ModeGitBranch},
+		help: "Create a branch from the selected one",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) > 0 {
+				base := m.filtered[m.cursor].Original.(git.Branch).Name
+				m.startPrompt("New branch from "+base+": ", "", func(m *model, name string) tea.Cmd {
+					return createBranchCmd(name, base)
+				})
+			}
+			return nil, true
+		},
+	},
+	{
+		name: "branch.rename", keys: []string{"alt+r"}, modes: []SearchMode{ModeGitBranch},
+		help: "Rename the selected branch",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) > 0 {
+				m.startRenameBranch()
+			}
+			return nil, true
+		},
+	},
+	{
+		name: "branch.worktree", keys: []string{"alt+w"}, modes: []SearchMode{ModeGitBranch},
+		help: "Create a worktree for the selected branch and cd into it",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) > 0 {
+				branch := m.filtered[m.cursor].Original.(git.Branch)
+				m.statusMsg = "Creating worktree for " + branch.Name + "..."
+				return addWorktreeCmd(branch), true
+			}
+			return nil, true
+		},
+	},
+	{
+		name: "history.scope", keys: []string{"ctrl+r"}, modes: []SearchMode{ModeHistory},
+		help: "List the commands run in this directory, in its git repository, or all again",
+		run: func(m *model) (tea.Cmd, bool) {
+			m.cycleHistoryScope()
+			return nil, true
+		},
+	},
+	{
+		name: "files.toggle-all", keys: []string{"ctrl+s"}, modes: []SearchMode{ModeFiles},
+		help: "Show hidden and ignored files too, or hide them again",
+		run: func(m *model) (tea.Cmd, bool) {
+			return m.toggleShowAllFiles(), true
+		},
+	},
+	{
+		name: "commit.toggle-all", keys: []string{"ctrl+l"}, modes: []SearchMode{ModeCommit},
+		help: "Search the commits of all refs, or of the current branch again",
+		run: func(m *model) (tea.Cmd, bool) {
+			return m.toggleCommitScope(), true
+		},
+	},
+	{
+		name: "worktree.prune", keys: []string{"alt+p"}, modes: []SearchMode{ModeWorktree},
+		help: "Prune stale worktrees",
+		run: func(m *model) (tea.Cmd, bool) {
+			return m.pruneWorktrees(), true
+		},
+	},
+	{
+		name: "status.stage", keys: []string{"alt+s"}, modes: []SearchMode{ModeStatus},
+		help: "Stage the selected files, or unstage them",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) == 0 {
+				return nil, true
+			}
+			var statusFiles []git.FileStatus
+			for _, item := range m.selectedItems() {
+				if f, ok := item.Original.(git.FileStatus); ok {
+					statusFiles = append(statusFiles, f)
+				}
+			}
+			if len(statusFiles) == 0 {
+				return nil, true
+			}
+			// Staging changes the files' status, so the marks would be stale.
+			m.marked = nil
+			return toggleStageCmd(statusFiles), true
+		},
+	},
+	{
+		name: "stash.pop", keys: []string{"alt+enter"}, modes: []SearchMode{ModeStash},
+		help: "Pop the selected stash instead of applying it",
+		run: func(m *model) (tea.Cmd, bool) {
+			return m.selectWith("pop"), true
+		},
+	},
+	{
+		name: "open", keys: []string{"alt+enter"}, modes: []SearchMode{ModeFiles, ModeGrep, ModeWorktree, ModeStatus},
+		help: "Open the selection in the editor instead of inserting it",
+		run: func(m *model) (tea.Cmd, bool) {
+			return m.selectWith("open"), true
+		},
+	},
+	{
+		name: "delete", keys: []string{"ctrl+x"}, modes: []SearchMode{ModeHistory, ModeGitBranch, ModeWorktree, ModeStash},
+		help: "Delete the selection after confirmation",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) > 0 {
+				return m.deleteSelected(), true
+			}
+			return nil, true
+		},
+	},
+	{
+		name: "select", keys: []string{"enter"},
+		help: "Choose the selection",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) > 0 || len(m.marked) > 0 {
+				return m.selectWith(""), true
+			}
+			if m.mode == ModeGitBranch && strings.TrimSpace(m.input.Value()) != "" {
+				// Nothing matches: offer to create a branch named after the query.
+				m.confirmCreateBranch(strings.TrimSpace(m.input.Value()), "")
+				return nil, true
+			}
+			return nil, false
+		},
+	},
+	{
+		name: "quit", keys: []string{"ctrl+c", "esc"},
+		help: "Cancel",
+		run: func(m *model) (tea.Cmd, bool) {
+			m.quitting = true
+			return tea.Quit, true
+		},
+	},
+	{
+		name: "up", keys: []string{"up", "ctrl+p"},
+		help: "Move the selection up",
+		run: func(m *model) (tea.Cmd, bool) {
+			m.moveCursor(m.up())
+			return nil, true
+		},
+	},
+	{
+		name: "down", keys: []string{"down", "ctrl+n"},
+		help: "Move the selection down",
+		run: func(m *model) (tea.Cmd, bool) {
+			m.moveCursor(-m.up())
+			return nil, true
+		},
+	},
+	{
+		name: "mark.up", keys: []string{"tab"},
+		help: "Mark the selected item for multi-select and move up",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) > 0 {
+				m.toggleMark(m.up())
+			}
+			return nil, true
+		},
+	},
+	{
+		name: "mark.down", keys: []string{"shift+tab"},
+		help: "Mark the selected item for multi-select and move down",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) > 0 {
+				m.toggleMark(-m.up())
+			}
+			return nil, true
+		},
+	},
+	{
+		name: "complete", keys: []string{"right"},
+		help: "Complete the query with the selected item (at the end of the query)",
+		run: func(m *model) (tea.Cmd, bool) {
+			// At the end of the query, complete it with the selected item like
+			// a fish autosuggestion; elsewhere the key moves the input cursor.
+			// Completing a content search hit would search for the whole line.
+			if len(m.filtered) > 0 && m.mode != ModeGrep && m.input.Position() == len([]rune(m.input.Value())) {
+				m.completeSelectedItem()
+				return nil, true
+			}
+			return nil, false
+		},
+	},
+	{
+		name: "copy", keys: []string{"ctrl+y"},
+		help: "Copy the selected item to the clipboard",
+		run: func(m *model) (tea.Cmd, bool) {
+			if len(m.filtered) == 0 {
+				return nil, false
+			}
+			m.copied = m.copyText(m.filtered[m.cursor])
+			_ = clipboard.WriteAll(m.copied)
+			m.quitting = true
+			return tea.Quit, true
+		},
+	},
+	{
+		name: "preview.scroll-down", keys: []string{"pgdown"},
+		help: "Scroll the preview down a page",
+		run: func(m *model) (tea.Cmd, bool) {
+			m.viewport.PageDown()
+			return nil, true
+		},
+	},
+	{
+		name: "preview.scroll-up", keys: []string{"pgup"},
+		help: "Scroll the preview up a page",
+		run: func(m *model) (tea.Cmd, bool) {
+			m.viewport.PageUp()
+			return nil, true
+		},
+	},
+	{
+		name: "preview.toggle", keys: []string{"alt+v"},
+		help: "Hide the preview, or show it again",
+		run: func(m *model) (tea.Cmd, bool) {
+			m.togglePreview()
+			return nil, true
+		},
+	},
+	{
+		name: "help", keys: []string{"f1"},
+		help: "Show the keys of the current mode",
+		run: func(m *model) (tea.Cmd, bool) {
+			m.showHelp = true
+			return nil, true
+		},
+	},
+	{
+		name: "mode.history", keys: []string{"ctrl+r"}, modes: browseModes,
+		help: "Search the command history",
+		run:  func(m *model) (tea.Cmd, bool) { return m.switchToHistoryMode(), true },
+	},
+	{
+		name: "mode.files", keys: []string{"ctrl+s"}, modes: browseModes,
+		help: "Search files",
+		run:  func(m *model) (tea.Cmd, bool) { return m.switchToFilesMode(), true },
+	},
+	{
+		name: "mode.grep", keys: []string{"ctrl+f"}, modes: browseModes,
+		help: "Search file contents",
+		run:  func(m *model) (tea.Cmd, bool) { return m.switchToGrepMode(), true },
+	},
+	{
+		name: "mode.branch", keys: []string{"ctrl+g"}, modes: browseModes,
+		help: "Search git branches",
+		run:  func(m *model) (tea.Cmd, bool) { return m.switchToGitBranchMode(), true },
+	},
+	{
+		name: "mode.worktree", keys: []string{"ctrl+w"}, modes: browseModes,
+		help: "Search git worktrees",
+		run:  func(m *model) (tea.Cmd, bool) { return m.switchToWorktreeMode(), true },
+	},
+	{
+		name: "mode.commit", keys: []string{"ctrl+l"}, modes: browseModes,
+		help: "Search the git commit log",
+		run:  func(m *model) (tea.Cmd, bool) { return m.switchToCommitMode(), true },
+	},
+	{
+		name: "mode.stash", keys: []string{"ctrl+t"}, modes: browseModes,
+		help: "Search git stashes",
+		run:  func(m *model) (tea.Cmd, bool) { return m.switchToStashMode(), true },
+	},
+	{
+		name: "mode.status", keys: []string{"ctrl+o"}, modes: browseModes,
+		help: "Search changed files in git status",
+		run:  func(m *model) (tea.Cmd, bool) { return m.switchToStatusMode(), true },
+	},
+}
+
+// keyBindings maps action names to their keys: the defaults, changed by the
+// [keys] table of the config file.
+var keyBindings = defaultKeyBindings()
+
+func defaultKeyBindings() map[string][]string {
+	bindings := make(map[string][]string, len(actions))
+	for _, a := range actions {
+		bindings[a.name] = a.keys
+	}
+	return bindings
+}
+
+// availableIn reports whether a is available in mode.
+func (a *action) availableIn(mode SearchMode) bool {
+	return a.modes == nil || slices.Contains(a.modes, mode)
+}
+
+// overlaps reports whether a and b are available in a mode in common.
+func (a *action) overlaps(b *action) bool {
+	if a.modes == nil || b.modes == nil {
+		return true
+	}
+	return slices.ContainsFunc(a.modes, b.availableIn)
+}
+
+// actionFor returns the action key triggers in mode, or nil when there is
+// none.
+func actionFor(mode SearchMode, key string) *action {
+	for i := range actions {
+		a := &actions[i]
+		if a.availableIn(mode) && slices.Contains(keyBindings[a.name], key) {
+			return a
+		}
+	}
+	return nil
+}
+
+// findAction returns the action named name, or nil when there is none.
+func findAction(name string) *action {
+	for i := range actions {
+		if actions[i].name == name {
+			return &actions[i]
+		}
+	}
+	return nil
+}
+
+// newKeyBindings returns the keyBindings for the [keys] table of the config
+// file. A key bound there is taken from the actions it was bound to by
+// default in the same modes, and an empty list unbinds an action.
+func newKeyBindings(keys config.Keys) (map[string][]string, error) {
+	// Sorted so errors about the same file are always the same.
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bindings := defaultKeyBindings()
+	for _, name := range names {
+		if findAction(name) == nil {
+			return nil, fmt.Errorf("keys.%s: unknown action (see the README for the list)", name)
+		}
+		bindings[name] = keys[name]
+	}
+	for _, name := range names {
+		a := findAction(name)
+		for _, key := range keys[name] {
+			if key == "" {
+				return nil, fmt.Errorf("keys.%s: empty key (use [] to unbind an action)", name)
+			}
+			for i := range actions {
+				b := &actions[i]
+				if b == a || !a.overlaps(b) || !slices.Contains(bindings[b.name], key) {
+					continue
+				}
+				if _, ok := keys[b.name]; ok {
+					return nil, fmt.Errorf("keys.%s: %s is already bound to %s", name, key, b.name)
+				}
+				bindings[b.name] = slices.DeleteFunc(slices.Clone(bindings[b.name]), func(k string) bool { return k == key })
+			}
+		}
+	}
+	return bindings, nil
+}
+
+// boundKeys returns the keys of a that trigger it in mode, leaving out those
+// an earlier action available in mode takes.
+func boundKeys(a *action, mode SearchMode) []string {
+	var keys []string
+	for _, key := range keyBindings[a.name] {
+		if actionFor(mode, key) == a {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// helpView renders the keys of the current mode, shown in place of the
+// preview.
+func (m model) helpView() string {
+	var b strings.Builder
+	b.WriteString(ui.HeaderStyle.Render("Keys") + "\n\n")
+	for i := range actions {
+		a := &actions[i]
+		if !a.availableIn(m.mode) {
+			continue
+		}
+		keys := boundKeys(a, m.mode)
+		if len(keys) == 0 {
+			continue
+		}
+		b.WriteString(ui.LabelStyle.Render(strings.Join(keys, " / ")) + "\n")
+		b.WriteString("  " + ui.ContentStyle.Render(a.help) + "\n")
+	}
+	b.WriteString("\n" + ui.InactiveContextStyle.Render("Press any key to close"))
+	return b.String()
+}
+
+// keysMarkdown renders the default key bindings as the Markdown table in the
+// README.
+func keysMarkdown() string {
+	var b strings.Builder
+	b.WriteString("| Key | Action | Modes | Does |\n")
+	b.WriteString("|-----|--------|-------|------|\n")
+	for _, a := range actions {
+		keys := make([]string, len(a.keys))
+		for i, key := range a.keys {
+			keys[i] = "`" + key + "`"
+		}
+		modes := "all"
+		if slices.Equal(a.modes, browseModes) {
+			modes = "all but stdin"
+		} else if a.modes != nil {
+			names := make([]string, len(a.modes))
+			for i, mode := range a.modes {
+				names[i] = mode.String()
+			}
+			modes = strings.Join(names, ", ")
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s | %s |\n", strings.Join(keys, " "), a.name, modes, a.help)
+	}
+	return b.String()
+}
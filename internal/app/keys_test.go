@@ -0,0 +1,68 @@
+package app
+
+import (
+	"flag"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"charm.land/bubbles/v2/textinput"
+	"charm.land/bubbles/v2/viewport"
+	tea "charm.land/bubbletea/v2"
+)
+
+var update = flag.Bool("update", false, "rewrite the key table in README.md")
+
+// keysSection matches the generated key table in README.md.
+var keysSection = regexp.MustCompile(`(?s)(<!-- keys:begin[^>]*-->\n).*?(<!-- keys:end -->)`)
+
+func TestREADMEKeys(t *testing.T) {
+	const path = "../../README.md"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme := string(data)
+	loc := keysSection.FindStringSubmatchIndex(readme)
+	if loc == nil {
+		t.Fatal("README.md has no keys:begin/keys:end markers")
+	}
+	// Everything between the markers is the table.
+	want := readme[:loc[3]] + keysMarkdown() + readme[loc[4]:]
+	if readme == want {
+		return
+	}
+	if *update {
+		if err := os.WriteFile(path, []byte(want), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	t.Error("the key table in README.md is out of date; run go test ./internal/app -run TestREADMEKeys -update")
+}
+
+func TestUpdate_HelpShowsKeysOfTheMode(t *testing.T) {
+	m := model{mode: ModeStash, input: textinput.New(), viewport: viewport.New(), previewCache: map[string]string{}, mainHeight: 40}
+	m.viewport.SetWidth(80)
+
+	m, _ = press(t, m, tea.Key{Code: tea.KeyF1})
+	if !m.showHelp {
+		t.Fatal("f1 did not show the help")
+	}
+	help := m.previewView()
+	for _, want := range []string{"alt+enter", "Pop the selected stash", "ctrl+r"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help is missing %q:\n%s", want, help)
+		}
+	}
+	if strings.Contains(help, "Open the selection") {
+		t.Errorf("help lists an action of other modes:\n%s", help)
+	}
+
+	// Any key closes it without acting.
+	m, _ = press(t, m, tea.Key{Code: 'r', Mod: tea.ModCtrl})
+	if m.showHelp || m.mode != ModeStash {
+		t.Errorf("after ctrl+r: showHelp = %v, mode = %v; want the help closed only", m.showHelp, m.mode)
+	}
+}
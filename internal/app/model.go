@@ -1,8 +1,13 @@
 package app
 
 import (
+	"context"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 
+	"charm.land/bubbles/v2/spinner"
 	"charm.land/bubbles/v2/textinput"
 	"charm.land/bubbles/v2/viewport"
 	tea "charm.land/bubbletea/v2"
@@ -12,10 +17,130 @@ import (
 )
 
 // Async load completion messages
-type historyLoadedMsg struct{ entries []history.Entry }
-type branchesLoadedMsg struct{ branches []git.Branch }
-type filesLoadedMsg struct{ entries []files.Entry }
-type worktreesLoadedMsg struct{ worktrees []git.Worktree }
+type historyLoadedMsg struct {
+	entries []history.Entry
+	cwd     string
+	gitRoot string
+}
+
+// historyCountedMsg carries how many commands typed the history commands
+// about to be deleted stand for, as one masked command can stand for several
+// typed with different secrets.
+type historyCountedMsg struct {
+	cmds  []string
+	typed int
+	err   error
+}
+
+// historyDeletedMsg reports how many entries deleting commands removed from
+// the history file.
+type historyDeletedMsg struct {
+	removed int
+	err     error
+}
+type branchesLoadedMsg struct {
+	branches []git.Branch
+	err      error
+}
+type branchDetailsLoadedMsg struct{ branches []git.Branch }
+
+// filesBatchMsg carries entries found by the file collection started as
+// generation gen; done is set once it has ended. next is where the following
+// batch is read from.
+type filesBatchMsg struct {
+	gen     int
+	entries []files.Entry
+	done    bool
+	next    <-chan []files.Entry
+}
+
+// grepBatchMsg carries matches found by the content search started as
+// generation gen; done is set once it has ended. next is where the following
+// batch is read from.
+type grepBatchMsg struct {
+	gen     int
+	matches []files.Match
+	done    bool
+	next    <-chan []files.Match
+}
+
+// stdinBatchMsg carries lines read from stdin; done is set once the input has
+// ended, and err when it ended early. next is where the following batch is
+// read from.
+type stdinBatchMsg struct {
+	lines []string
+	done  bool
+	err   error
+	next  <-chan []string
+}
+type worktreesLoadedMsg struct {
+	worktrees []git.Worktree
+	err       error
+}
+type worktreeDetailsLoadedMsg struct{ worktrees []git.Worktree }
+type stashesLoadedMsg struct {
+	stashes []git.Stash
+	err     error
+}
+type statusLoadedMsg struct {
+	status git.Status
+	err    error
+}
+
+// gitOpDoneMsg reports the result of a git command run from the TUI (e.g.
+// staging a file); reload refreshes the current mode's data afterwards and
+// done is shown in the status line on success.
+type gitOpDoneMsg struct {
+	err    error
+	done   string
+	reload tea.Cmd
+}
+
+// branchCreatedMsg reports a branch created from branch mode, which is then
+// switched to.
+type branchCreatedMsg struct {
+	name string
+	err  error
+}
+
+// branchMergeCheckedMsg carries which of the branches about to be deleted
+// are not merged, so the confirmation can warn about losing commits.
+type branchMergeCheckedMsg struct {
+	names    []string
+	unmerged []string
+	err      error
+}
+
+// worktreeCreatedMsg reports a worktree created from branch mode, which is
+// then changed into.
+type worktreeCreatedMsg struct {
+	path string
+	err  error
+}
+
+// worktreeChangesCheckedMsg carries how many uncommitted changes the
+// worktrees about to be removed have, by path, so the confirmation can warn
+// about losing them.
+type worktreeChangesCheckedMsg struct {
+	worktrees []git.Worktree
+	changes   map[string]int
+	err       error
+}
+
+type commitsLoadedMsg struct {
+	commits []git.Commit
+	all     bool
+	err     error
+}
+
+// previewLoadedMsg carries a preview rendered in the background for the item
+// identified by key. ctx is cancelled once another preview supersedes it.
+type previewLoadedMsg struct {
+	ctx      context.Context
+	key      string
+	cacheKey string
+	content  string
+}
 
 // Filter debounce message
 type filterTickMsg struct{ query string }
@@ -28,6 +153,20 @@ const (
 	ModeGitBranch
 	ModeFiles
 	ModeWorktree
+	ModeCommit
+	ModeStash
+	ModeStatus
+	ModeGrep
+	ModeStdin // Lines piped into fuzz --stdin; the only mode of such a session
+)
+
+// historyScope limits history mode to the commands run in a directory
+type historyScope int
+
+const (
+	scopeAll  historyScope = iota
+	scopeDir               // Commands run in the current directory
+	scopeRepo              // Commands run anywhere in the git working tree of the current directory
 )
 
 // Item represents a search result item
@@ -35,11 +174,28 @@ type Item struct {
 	Text           string
 	SearchText     string      // Fuzzy match target; falls back to Text when empty (e.g. worktree path + branch)
 	Index          int         // Index in the original source slice
-	Original       interface{} // The original object (history.Entry, git.Branch, or files.Entry)
+	Original       interface{} // The original object (history.Entry, git.Branch, files.Entry, ...)
 	IsCurrent      bool        // For git branch (icon logic)
 	IsRemote       bool        // For git branch (icon logic)
 	IsDir          bool        // For files (directory indicator)
+	InDir          bool        // For history: run in the current directory (ranked higher)
 	MatchedIndexes []int       // Indexes of matched characters for highlighting
+	Score          float64     // Ranking score against the query; zero when unfiltered
+}
+
+// confirmation is a yes/no question shown in the input line. onYes runs when
+// the user answers "y"; any other key cancels it.
+type confirmation struct {
+	prompt string
+	onYes  func(m *model) tea.Cmd
+}
+
+// textPrompt reads a value (e.g. a new branch name) in the input box in place
+// of the search query. onSubmit runs on enter; esc restores the query.
+type textPrompt struct {
+	onSubmit    func(m *model, value string) tea.Cmd
+	savedQuery  string
+	savedPrompt string
 }
 
 // model represents the application state
@@ -50,72 +206,439 @@ type model struct {
 
 	// Data sources
 	historyEntries []history.Entry
+	historyScope   historyScope
+	cwd            string // Where history is scoped to and ranked higher
+	gitRoot        string // Top of the git working tree cwd is in; empty outside of one
 	gitBranches    []git.Branch
 	fileEntries    []files.Entry
+	filesDone      bool               // fileEntries holds a complete collection
+	filesGen       int                // Generation of the current file collection
+	filesCancel    context.CancelFunc // Stops the file collection in progress
+	filesShowAll   bool               // Files mode lists hidden and ignored files too
+	grepMatches    []files.Match
+	grepGen        int                // Generation of the current content search
+	grepCancel     context.CancelFunc // Stops the content search in progress
+	stdinLines     []string
+	stdinDone      bool            // stdinLines holds all of the input
+	stdin          <-chan []string // Where stdin mode reads its first batch from
+	stdinErr       <-chan error    // Why reading stdin ended, sent once stdin is closed
 	worktrees      []git.Worktree
+	commits        []git.Commit
+	commitsAll     bool // Commit log covers all refs instead of the current branch
+	stashes        []git.Stash
+	gitStatus      git.Status
 
 	// Items state
-	allItems       []Item           // All items for current mode (sorted newest/priority first)
-	allItemsStr    []string         // Pre-built search strings for fuzzy matching (avoids per-keystroke allocation)
-	filtered       []Item           // Filtered items
-
-	cursor      int
-	offset      int
-	choice      *string // Result string to print
-	choiceIsDir bool    // For files mode: whether the choice is a directory
-	fetchBranch bool    // True when ctrl+g selects current branch for git pull
-	quitting    bool
-	statusMsg   string  // Transient status message (e.g., warning)
-	loading     bool   // True while async data loading is in progress
+	allItems    []Item   // All items for current mode, in list order or, see appendsItems, reversed
+	allItemsStr []string // Pre-built search strings for fuzzy matching (avoids per-keystroke allocation)
+	filtered    []Item   // Filtered items
+
+	cursor       int
+	offset       int      // Index of the first visible item of filtered
+	choices      []string // Result values to print; empty when nothing was chosen
+	marked       []Item   // Items marked for multi-select, in the order they were marked
+	choiceLines  []int    // For content search: the line of each choice, in step with choices
+	choiceIsDir  bool     // For files mode: whether the choice is a directory
+	choiceAction string   // Secondary action for the choice (e.g. "pop" in stash mode); empty for enter
+	fetchBranch  bool     // True when ctrl+g selects current branch for git pull
+	actionErr    error    // Why acting on the choices after the TUI ended failed (e.g. git pull)
+	copied       string   // Text copied to the clipboard with ctrl+y
+	quitting     bool
+	statusMsg    string        // Transient status message (e.g., warning)
+	confirm      *confirmation // Pending yes/no question, answered by the next key press
+	prompt       *textPrompt   // Pending text question, typed into the input box
+	showHelp     bool          // The keys of the mode are shown in place of the preview
+	loading      bool          // True while async data loading is in progress
+	spinner      spinner.Model // Shown while files are being collected
 
 	pendingQuery string // For filter debounce
 
-	width      int
-	height     int
-	ready      bool
-	listWidth  int
-	mainHeight int
+	width         int
+	height        int
+	ready         bool
+	listWidth     int
+	mainHeight    int  // Height of the list
+	reverse       bool // The list runs down from the best match, below the input
+	previewHidden bool // Only the list is shown
+	previewBelow  bool // The preview is below the list rather than beside it
 
 	// Preview cache
-	previewCache     map[string]string // Cache for file previews
-	lastPreviewKey   string            // Identifies the item the preview was rendered for
+	previewCache   map[string]string  // Cache for file previews
+	lastPreviewKey string             // Identifies the item the preview was rendered for
+	previewCancel  context.CancelFunc // Cancels the background preview render in progress
+	previewCmd     tea.Cmd            // Preview render requested by updatePreview, run by Update
 }
 
 // Init initializes the model
 func (m model) Init() tea.Cmd {
+	if m.mode == ModeStdin {
+		return tea.Batch(waitForStdinCmd(m.stdin, m.stdinErr), m.spinner.Tick)
+	}
 	return loadHistoryCmd()
 }
 
 func loadHistoryCmd() tea.Cmd {
 	return func() tea.Msg {
-		p := history.NewParser()
-		return historyLoadedMsg{entries: p.Parse()}
+		p := newHistoryParser()
+		cwd, gitRoot := historyDirs()
+		return historyLoadedMsg{entries: p.Parse(), cwd: cwd, gitRoot: gitRoot}
 	}
 }
 
+// newHistoryParser returns a parser of the fish history that masks secrets as
+// the settings choose.
+func newHistoryParser() *history.Parser {
+	p := history.NewParser()
+	// Configure has made sure the rules compile.
+	p.Redactor, _ = settings.Secrets.Redactor()
+	return p
+}
+
+// revealCommands returns history commands, as listed, the way they were
+// typed: every command masked into one of them, newest first. Those that
+// cannot be found again stay masked.
+func revealCommands(cmds []string) []string {
+	revealed, _ := newHistoryParser().Reveal(cmds)
+	return slices.Concat(revealed...)
+}
+
+// countHistoryCmd counts the commands typed that the listed history commands
+// cmds stand for, so deleting them can say how many go.
+func countHistoryCmd(cmds []string) tea.Cmd {
+	return func() tea.Msg {
+		revealed, err := newHistoryParser().Reveal(cmds)
+		typed := 0
+		for _, r := range revealed {
+			typed += len(r)
+		}
+		return historyCountedMsg{cmds: cmds, typed: typed, err: err}
+	}
+}
+
+// deleteHistoryCmd deletes every entry of cmds from the history file.
+func deleteHistoryCmd(cmds []string) tea.Cmd {
+	return func() tea.Msg {
+		removed, err := newHistoryParser().Delete(cmds)
+		return historyDeletedMsg{removed: removed, err: err}
+	}
+}
+
+// historyDirs returns the directories history can be scoped to: the current
+// one and the top of the git working tree it is in, empty outside of one.
+func historyDirs() (cwd, gitRoot string) {
+	cwd, _ = os.Getwd()
+	gitRoot, _ = git.NewRepository(".").Root()
+	return cwd, gitRoot
+}
+
 func loadBranchesCmd() tea.Cmd {
 	return func() tea.Msg {
 		r := git.NewRepository(".")
-		branches, _ := r.Branches()
-		return branchesLoadedMsg{branches: branches}
+		branches, err := r.Branches()
+		return branchesLoadedMsg{branches: branches, err: err}
+	}
+}
+
+// startFileStream starts collecting files under the current directory in the
+// background, replacing any collection still in progress. Entries arrive as
+// filesBatchMsg values, read by waitForFilesCmd.
+func (m *model) startFileStream() tea.Cmd {
+	m.cancelFileStream()
+	m.fileEntries = nil
+	m.filesDone = false
+	m.filesGen++
+
+	gen := m.filesGen
+	c, err := newFileCollector(m.filesShowAll)
+	if err != nil {
+		return func() tea.Msg { return filesBatchMsg{gen: gen, done: true} }
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.filesCancel = cancel
+	out := make(chan []files.Entry)
+	go c.Stream(ctx, out)
+	return tea.Batch(waitForFilesCmd(gen, out), m.spinner.Tick)
+}
+
+// newFileCollector returns a collector for the current directory, skipping
+// the configured directories and limited to $FUZZ_FISH_MAX_FILES entries when
+// set. showAll includes hidden and ignored files.
+func newFileCollector(showAll bool) (*files.Collector, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	c := files.NewCollector(cwd)
+	c.SetSkipDirs(settings.Files.SkipDirs)
+	if n, err := strconv.Atoi(os.Getenv("FUZZ_FISH_MAX_FILES")); err == nil && n > 0 {
+		c.MaxFiles = n
+	}
+	c.Hidden = showAll
+	c.NoIgnore = showAll
+	return c, nil
+}
+
+// waitForFilesCmd reads the next batch of a file collection.
+func waitForFilesCmd(gen int, out <-chan []files.Entry) tea.Cmd {
+	return func() tea.Msg {
+		entries, ok := <-out
+		return filesBatchMsg{gen: gen, entries: entries, done: !ok, next: out}
+	}
+}
+
+// cancelFileStream stops a file collection still in progress. The entries
+// found so far are kept, but filesDone stays false so files mode collects
+// them again next time.
+func (m *model) cancelFileStream() {
+	if m.filesCancel != nil {
+		m.filesCancel()
+		m.filesCancel = nil
+	}
+}
+
+// startGrep searches file contents under the current directory for query in
+// the background, replacing any search still in progress. Matches arrive as
+// grepBatchMsg values, read by waitForGrepCmd. An empty query lists nothing.
+func (m *model) startGrep(query string) tea.Cmd {
+	m.cancelGrep()
+	m.grepMatches = nil
+	m.grepGen++
+
+	gen := m.grepGen
+	c, err := newFileCollector(m.filesShowAll)
+	if err != nil || strings.TrimSpace(query) == "" {
+		return func() tea.Msg { return grepBatchMsg{gen: gen, done: true} }
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.grepCancel = cancel
+	out := make(chan []files.Match)
+	go c.Grep(ctx, query, out)
+	return tea.Batch(waitForGrepCmd(gen, out), m.spinner.Tick)
+}
+
+// waitForGrepCmd reads the next batch of a content search.
+func waitForGrepCmd(gen int, out <-chan []files.Match) tea.Cmd {
+	return func() tea.Msg {
+		matches, ok := <-out
+		return grepBatchMsg{gen: gen, matches: matches, done: !ok, next: out}
+	}
+}
+
+// cancelGrep stops a content search still in progress, e.g. once the query it
+// searches for has changed.
+func (m *model) cancelGrep() {
+	if m.grepCancel != nil {
+		m.grepCancel()
+		m.grepCancel = nil
+	}
+}
+
+// waitForStdinCmd reads the next batch of lines from stdin, and why reading
+// ended from errc once there are none left.
+func waitForStdinCmd(out <-chan []string, errc <-chan error) tea.Cmd {
+	return func() tea.Msg {
+		lines, ok := <-out
+		if !ok {
+			return stdinBatchMsg{done: true, err: <-errc}
+		}
+		return stdinBatchMsg{lines: lines, next: out}
 	}
 }
 
-func loadFilesCmd() tea.Cmd {
+// loadBranchDetailsCmd fills in commit metadata for already listed branches.
+// It runs after loadBranchesCmd so the list shows up without waiting on it.
+func loadBranchDetailsCmd(branches []git.Branch) tea.Cmd {
 	return func() tea.Msg {
-		cwd, err := os.Getwd()
+		r := git.NewRepository(".")
+		loaded, err := r.LoadDetails(branches)
 		if err != nil {
-			return filesLoadedMsg{}
+			return nil
 		}
-		c := files.NewCollector(cwd)
-		return filesLoadedMsg{entries: c.Collect()}
+		return branchDetailsLoadedMsg{branches: loaded}
 	}
 }
 
 func loadWorktreesCmd() tea.Cmd {
 	return func() tea.Msg {
 		r := git.NewRepository(".")
-		worktrees, _ := r.Worktrees()
-		return worktreesLoadedMsg{worktrees: worktrees}
+		worktrees, err := r.Worktrees()
+		return worktreesLoadedMsg{worktrees: worktrees, err: err}
+	}
+}
+
+// loadWorktreeDetailsCmd fills in the status and recent commits of already
+// listed worktrees, which takes a few git commands per worktree.
+func loadWorktreeDetailsCmd(worktrees []git.Worktree) tea.Cmd {
+	return func() tea.Msg {
+		return worktreeDetailsLoadedMsg{worktrees: git.LoadWorktreeDetails(worktrees)}
+	}
+}
+
+func loadCommitsCmd(all bool) tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		commits, err := r.Commits(all)
+		return commitsLoadedMsg{commits: commits, all: all, err: err}
+	}
+}
+
+func loadStashesCmd() tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		stashes, err := r.Stashes()
+		return stashesLoadedMsg{stashes: stashes, err: err}
+	}
+}
+
+func loadStatusCmd() tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		status, err := r.Status()
+		return statusLoadedMsg{status: status, err: err}
+	}
+}
+
+// toggleStageCmd stages files when any of them has unstaged changes (or is
+// untracked) and unstages them when they are all fully staged, in a single
+// git command. The files are all in the same worktree.
+func toggleStageCmd(statusFiles []git.FileStatus) tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		stage := slices.ContainsFunc(statusFiles, func(f git.FileStatus) bool {
+			return f.IsUntracked() || f.HasUnstaged()
+		})
+		var paths []string
+		for _, f := range statusFiles {
+			paths = append(paths, f.Path)
+			if !stage && f.OrigPath != "" {
+				paths = append(paths, f.OrigPath)
+			}
+		}
+		var err error
+		if stage {
+			err = r.Stage(statusFiles[0].Root, paths...)
+		} else {
+			err = r.Unstage(statusFiles[0].Root, paths...)
+		}
+		return gitOpDoneMsg{err: err, reload: loadStatusCmd()}
+	}
+}
+
+// createBranchCmd creates a branch from base (HEAD when empty).
+func createBranchCmd(name, base string) tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		return branchCreatedMsg{name: name, err: r.CreateBranch(name, base)}
+	}
+}
+
+func renameBranchCmd(oldName, newName string) tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		err := r.RenameBranch(oldName, newName)
+		return gitOpDoneMsg{err: err, done: "Renamed " + oldName + " to " + newName, reload: loadBranchesCmd()}
+	}
+}
+
+func checkBranchesMergedCmd(names []string) tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		var unmerged []string
+		for _, name := range names {
+			merged, err := r.IsMerged(name)
+			if err != nil {
+				return branchMergeCheckedMsg{names: names, err: err}
+			}
+			if !merged {
+				unmerged = append(unmerged, name)
+			}
+		}
+		return branchMergeCheckedMsg{names: names, unmerged: unmerged}
+	}
+}
+
+// deleteBranchesCmd deletes branches, with force for the unmerged ones. It
+// stops at the first branch git refuses to delete.
+func deleteBranchesCmd(names, unmerged []string) tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		for _, name := range names {
+			if err := r.DeleteBranch(name, slices.Contains(unmerged, name)); err != nil {
+				return gitOpDoneMsg{err: err, reload: loadBranchesCmd()}
+			}
+		}
+		done := "Deleted branch " + names[0]
+		if len(names) > 1 {
+			done = "Deleted " + strconv.Itoa(len(names)) + " branches"
+		}
+		return gitOpDoneMsg{done: done, reload: loadBranchesCmd()}
+	}
+}
+
+// worktreePathTemplate returns the path template for new worktrees:
+// $FUZZ_FISH_WORKTREE_PATH when set, the configured one otherwise.
+func worktreePathTemplate() string {
+	if t := os.Getenv("FUZZ_FISH_WORKTREE_PATH"); t != "" {
+		return t
+	}
+	return settings.Git.WorktreePath
+}
+
+func addWorktreeCmd(branch git.Branch) tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		path, err := r.AddWorktree(worktreePathTemplate(), branch)
+		return worktreeCreatedMsg{path: path, err: err}
+	}
+}
+
+// checkWorktreeChangesCmd counts the uncommitted changes of worktrees.
+// Prunable ones are skipped: their directories are gone, so there is nothing
+// to check or lose.
+func checkWorktreeChangesCmd(worktrees []git.Worktree) tea.Cmd {
+	return func() tea.Msg {
+		changes := make(map[string]int, len(worktrees))
+		for _, wt := range worktrees {
+			if wt.Prunable {
+				continue
+			}
+			n, err := wt.Changes()
+			if err != nil {
+				return worktreeChangesCheckedMsg{worktrees: worktrees, err: err}
+			}
+			changes[wt.Path] = n
+		}
+		return worktreeChangesCheckedMsg{worktrees: worktrees, changes: changes}
+	}
+}
+
+// removeWorktreesCmd removes worktrees, with force for the ones with
+// uncommitted changes and the prunable ones. It stops at the first worktree
+// git refuses to remove.
+func removeWorktreesCmd(worktrees []git.Worktree, changes map[string]int) tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		for _, wt := range worktrees {
+			if err := r.RemoveWorktree(wt.Path, changes[wt.Path] > 0 || wt.Prunable); err != nil {
+				return gitOpDoneMsg{err: err, reload: loadWorktreesCmd()}
+			}
+		}
+		done := "Removed worktree " + worktrees[0].Path
+		if len(worktrees) > 1 {
+			done = "Removed " + strconv.Itoa(len(worktrees)) + " worktrees"
+		}
+		return gitOpDoneMsg{done: done, reload: loadWorktreesCmd()}
+	}
+}
+
+// pruneWorktreesCmd prunes stale worktrees; stale is how many the list
+// marked as prunable, for the status line.
+func pruneWorktreesCmd(stale int) tea.Cmd {
+	return func() tea.Msg {
+		r := git.NewRepository(".")
+		err := r.PruneWorktrees()
+		return gitOpDoneMsg{err: err, done: "Pruned " + strconv.Itoa(stale) + " stale worktree(s)", reload: loadWorktreesCmd()}
 	}
 }
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Output formats of the result printed when the TUI exits
+const (
+	OutputText = "text" // PREFIX:value records terminated by NUL, read by conf.d/fuzz.fish
+	OutputJSON = "json" // One Result object
+)
+
+// ResultVersion is the version of the JSON result schema. It only changes
+// when a field is removed or changes meaning; readers should ignore fields
+// and actions they do not know.
+const ResultVersion = 1
+
+// Exit reasons reported in Result.Exit
+const (
+	ExitSelected  = "selected"  // Values were chosen for the shell to act on
+	ExitHandled   = "handled"   // fuzz acted on the values itself (e.g. git pull)
+	ExitFailed    = "failed"    // fuzz acted on the values itself, but that failed; see Result.Error
+	ExitCopied    = "copied"    // The value was copied to the clipboard
+	ExitCancelled = "cancelled" // Nothing was chosen
+)
+
+// Result is the outcome of a session, printed with --output json
+type Result struct {
+	Version int      `json:"version"`
+	Action  string   `json:"action"` // What to do with Values, e.g. "cmd", "file", "open"; empty when cancelled
+	Mode    string   `json:"mode"`   // The mode the session ended in, e.g. "history"
+	Values  []string `json:"values"`
+	Lines   []int    `json:"lines,omitempty"` // For "goto": the line to open each of Values at
+	Query   string   `json:"query"`
+	Exit    string   `json:"exit"`
+	Error   string   `json:"error,omitempty"` // Why acting on Values failed, when Exit is "failed"
+}
+
+// modeNames are the names of the modes in Result.Mode
+var modeNames = map[SearchMode]string{
+	ModeHistory:   "history",
+	ModeGitBranch: "branch",
+	ModeFiles:     "files",
+	ModeWorktree:  "worktree",
+	ModeCommit:    "commit",
+	ModeStash:     "stash",
+	ModeStatus:    "status",
+	ModeGrep:      "grep",
+	ModeStdin:     "stdin",
+}
+
+// String returns the name of the mode used in JSON output
+func (mode SearchMode) String() string {
+	return modeNames[mode]
+}
+
+// result returns the outcome of the session that ended with m. Actions are
+// the text output prefixes in lower case.
+func (m model) result() Result {
+	r := Result{
+		Version: ResultVersion,
+		Mode:    m.mode.String(),
+		Values:  []string{},
+		Query:   m.input.Value(),
+		Exit:    ExitCancelled,
+	}
+	switch {
+	case len(m.choices) > 0 && m.mode == ModeGitBranch && m.fetchBranch:
+		r.Action = "pull"
+		r.Values = m.choices
+		r.Exit = ExitHandled
+		if m.actionErr != nil {
+			r.Exit = ExitFailed
+			r.Error = m.actionErr.Error()
+		}
+	case len(m.choices) > 0:
+		r.Action = strings.ToLower(m.resultPrefix())
+		r.Values = m.choices
+		r.Lines = m.choiceLines
+		r.Exit = ExitSelected
+	case m.copied != "":
+		r.Action = "copy"
+		r.Values = []string{m.copied}
+		r.Exit = ExitCopied
+	}
+	return r
+}
+
+// writeJSON writes r as a single line of JSON.
+func writeJSON(w io.Writer, r Result) error {
+	return json.NewEncoder(w).Encode(r)
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"charm.land/bubbles/v2/textinput"
+)
+
+func TestResult(t *testing.T) {
+	input := textinput.New()
+	input.SetValue("vim")
+
+	tests := []struct {
+		name string
+		m    model
+		want Result
+	}{
+		{
+			name: "cancelled",
+			m:    model{mode: ModeHistory, input: input},
+			want: Result{Mode: "history", Values: []string{}, Exit: ExitCancelled},
+		},
+		{
+			name: "selected files",
+			m:    model{mode: ModeFiles, input: input, choices: []string{"a.go", "b c.go"}},
+			want: Result{Action: "file", Mode: "files", Values: []string{"a.go", "b c.go"}, Exit: ExitSelected},
+		},
+		{
+			name: "content search hits",
+			m:    model{mode: ModeGrep, input: input, choices: []string{"a.go", "notes:2"}, choiceLines: []int{12, 7}},
+			want: Result{Action: "goto", Mode: "grep", Values: []string{"a.go", "notes:2"}, Lines: []int{12, 7}, Exit: ExitSelected},
+		},
+		{
+			name: "stash pop",
+			m:    model{mode: ModeStash, input: input, choices: []string{"stash@{0}"}, choiceAction: "pop"},
+			want: Result{Action: "stash_pop", Mode: "stash", Values: []string{"stash@{0}"}, Exit: ExitSelected},
+		},
+		{
+			name: "pulled",
+			m:    model{mode: ModeGitBranch, input: input, choices: []string{"main"}, fetchBranch: true},
+			want: Result{Action: "pull", Mode: "branch", Values: []string{"main"}, Exit: ExitHandled},
+		},
+		{
+			name: "pull failed",
+			m:    model{mode: ModeGitBranch, input: input, choices: []string{"main"}, fetchBranch: true, actionErr: errors.New("git pull failed: exit status 1")},
+			want: Result{Action: "pull", Mode: "branch", Values: []string{"main"}, Exit: ExitFailed, Error: "git pull failed: exit status 1"},
+		},
+		{
+			name: "copied",
+			m:    model{mode: ModeCommit, input: input, copied: "abc123"},
+			want: Result{Action: "copy", Mode: "commit", Values: []string{"abc123"}, Exit: ExitCopied},
+		},
+		{
+			name: "stdin lines",
+			m:    model{mode: ModeStdin, input: input, choices: []string{"one", "two"}},
+			want: Result{Action: "select", Mode: "stdin", Values: []string{"one", "two"}, Exit: ExitSelected},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.Version = ResultVersion
+			tt.want.Query = "vim"
+			got := tt.m.result()
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if !bytes.Equal(gotJSON, wantJSON) {
+				t.Errorf("result() = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestWriteJSON_KeepsNewlinesInValues(t *testing.T) {
+	var buf bytes.Buffer
+	r := Result{Version: ResultVersion, Action: "cmd", Mode: "history", Values: []string{"for x in a b\n  echo $x\nend"}, Exit: ExitSelected}
+	if err := writeJSON(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 1 {
+		t.Errorf("writeJSON() wrote %d lines, want a single line: %s", n, buf.Bytes())
+	}
+	var decoded Result
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Values[0] != r.Values[0] {
+		t.Errorf("decoded value = %q, want %q", decoded.Values[0], r.Values[0])
+	}
+}
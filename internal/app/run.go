@@ -1,49 +1,92 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 
+	"charm.land/bubbles/v2/spinner"
 	"charm.land/bubbles/v2/textinput"
 	"charm.land/bubbles/v2/viewport"
 	tea "charm.land/bubbletea/v2"
-	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/colorprofile"
+	"github.com/jedipunkz/fuzz.fish/internal/config"
+	"github.com/jedipunkz/fuzz.fish/internal/files"
 	"github.com/jedipunkz/fuzz.fish/internal/ui"
 )
 
-// Run starts the application. initialQuery pre-fills the search box (e.g. with
-// the current Fish command line) so results are already filtered on startup.
-func Run(initialQuery string) {
+// Options configure a session
+type Options struct {
+	// Query pre-fills the search box (e.g. with the current Fish command
+	// line) so results are already filtered on startup.
+	Query string
+	// Output is the format of the result: OutputText or OutputJSON.
+	Output string
+	// Stdin picks from the lines read from standard input instead of the
+	// shell history, and prints the chosen lines as they are.
+	Stdin bool
+}
+
+// ExitCancelledStatus is the exit status of a stdin session that ended
+// without a choice, so scripts can tell it from an empty selection.
+const ExitCancelledStatus = 130
+
+// Run starts the application and returns its exit status.
+func Run(opts Options) int {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open /dev/tty: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = tty.Close() }()
+
+	// NO_COLOR is honoured whatever its value, as https://no-color.org asks.
+	if os.Getenv("NO_COLOR") != "" || colorprofile.Detect(tty, os.Environ()) <= colorprofile.ASCII {
+		ui.SetMonochrome(true)
+		buildStyles()
+	}
+
 	ti := textinput.New()
 	ti.Placeholder = ""
 	ti.CharLimit = 156
 	s := textinput.DefaultDarkStyles()
-	s.Focused.Prompt = lipgloss.NewStyle().Foreground(lipgloss.Color(ui.ColorCyan))
-	s.Focused.Text = lipgloss.NewStyle().Foreground(lipgloss.Color(ui.ColorForeground))
+	s.Focused.Prompt = promptStyle
+	s.Focused.Text = inputTextStyle
 	s.Cursor.Blink = false
 	ti.SetStyles(s)
 	ti.SetVirtualCursor(false)
 	ti.Focus()
-	if initialQuery != "" {
-		ti.SetValue(initialQuery)
+	if opts.Query != "" {
+		ti.SetValue(opts.Query)
 		ti.CursorEnd()
 	}
 
 	m := model{
-		mode:         ModeHistory,
-		input:        ti,
-		viewport:     viewport.New(),
-		previewCache: make(map[string]string),
-		loading:      true,
+		mode:          ModeHistory,
+		input:         ti,
+		viewport:      viewport.New(),
+		previewCache:  make(map[string]string),
+		loading:       true,
+		reverse:       settings.Layout.Reverse,
+		previewHidden: settings.Layout.Preview == config.PreviewHidden,
+		spinner: spinner.New(
+			spinner.WithSpinner(spinner.MiniDot),
+			spinner.WithStyle(spinnerStyle),
+		),
 	}
-
-	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to open /dev/tty: %v\n", err)
-		os.Exit(1)
+	if opts.Stdin {
+		// The TUI reads keys from /dev/tty, which leaves stdin to the data.
+		lines := make(chan []string)
+		readErr := make(chan error, 1)
+		go func() {
+			readErr <- files.StreamLines(context.Background(), os.Stdin, lines)
+		}()
+		m.mode = ModeStdin
+		m.stdin = lines
+		m.stdinErr = readErr
 	}
-	defer func() { _ = tty.Close() }()
 
 	prog := tea.NewProgram(m, tea.WithInput(tty), tea.WithOutput(tty))
 	finalModel, err := prog.Run()
@@ -52,32 +95,111 @@ func Run(initialQuery string) {
 		os.Exit(1)
 	}
 
-	if m, ok := finalModel.(model); ok {
-		if m.choice != nil {
-			switch m.mode {
-			case ModeHistory:
-				fmt.Printf("CMD:%s", *m.choice)
-			case ModeGitBranch:
-				if m.fetchBranch {
-					cmd := exec.Command("git", "pull", "origin", *m.choice)
-					cmd.Stdin = tty
-					cmd.Stdout = tty
-					cmd.Stderr = tty
-					if err := cmd.Run(); err != nil {
-						fmt.Fprintf(os.Stderr, "git pull failed: %v\n", err)
-					}
-				} else {
-					fmt.Printf("BRANCH:%s", *m.choice)
-				}
-			case ModeFiles:
-				if m.choiceIsDir {
-					fmt.Printf("DIR:%s", *m.choice)
-				} else {
-					fmt.Printf("FILE:%s", *m.choice)
-				}
-			case ModeWorktree:
-				fmt.Printf("DIR:%s", *m.choice)
-			}
+	m, ok := finalModel.(model)
+	if !ok {
+		return 0
+	}
+	m.cancelFileStream()
+	m.cancelGrep()
+
+	if len(m.choices) > 0 && m.mode == ModeGitBranch && m.fetchBranch {
+		cmd := exec.Command("git", "pull", "origin", m.choices[0])
+		cmd.Stdin = tty
+		cmd.Stdout = tty
+		cmd.Stderr = tty
+		if err := cmd.Run(); err != nil {
+			m.actionErr = fmt.Errorf("git pull failed: %w", err)
+			fmt.Fprintln(os.Stderr, m.actionErr)
+		}
+	}
+
+	if opts.Output == OutputJSON {
+		if err := writeJSON(os.Stdout, m.result()); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write result: %v\n", err)
+			os.Exit(1)
+		}
+		return m.exitStatus()
+	}
+	if len(m.choices) == 0 || m.fetchBranch {
+		return m.exitStatus()
+	}
+	if m.mode == ModeStdin {
+		// Picked lines are printed as they were read, like other pickers
+		// do, so they can be used in pipelines and command substitutions.
+		for _, choice := range m.choices {
+			fmt.Println(choice)
+		}
+		return 0
+	}
+	// Every choice is printed as PREFIX:value and terminated by a NUL byte,
+	// which cannot occur in a value, so several of them (e.g. multi-line
+	// commands) can be told apart. Content search hits are GOTO:line:path.
+	prefix := m.resultPrefix()
+	for i, choice := range m.choices {
+		if m.choiceLines != nil {
+			choice = strconv.Itoa(m.choiceLines[i]) + ":" + choice
+		}
+		fmt.Printf("%s:%s\x00", prefix, choice)
+	}
+	return 0
+}
+
+// exitStatus returns the exit status of the session that ended with m.
+func (m model) exitStatus() int {
+	// Copying a line with ctrl+y chooses nothing, but is no cancellation.
+	if m.mode == ModeStdin && m.result().Exit == ExitCancelled {
+		return ExitCancelledStatus
+	}
+	return 0
+}
+
+// resultPrefix returns the prefix telling the shell what to do with the
+// choices.
+func (m model) resultPrefix() string {
+	switch m.mode {
+	case ModeHistory:
+		return "CMD"
+	case ModeGitBranch:
+		if m.choiceAction == "worktree" {
+			return "DIR"
+		}
+		return "BRANCH"
+	case ModeFiles:
+		if m.choiceAction == "open" {
+			return "OPEN"
+		} else if m.choiceIsDir {
+			return "DIR"
+		}
+		return "FILE"
+	case ModeWorktree:
+		if m.choiceAction == "open" {
+			return "OPEN"
+		} else if len(m.choices) > 1 {
+			// Several worktrees cannot all be changed into, so their paths
+			// are inserted instead.
+			return "FILE"
+		}
+		return "DIR"
+	case ModeStatus:
+		if m.choiceAction == "open" {
+			return "OPEN"
+		}
+		return "FILE"
+	case ModeGrep:
+		// The choices are opened at the matching line.
+		return "GOTO"
+	case ModeCommit:
+		return "COMMIT"
+	case ModeStash:
+		switch m.choiceAction {
+		case "pop":
+			return "STASH_POP"
+		case "drop":
+			return "STASH_DROP"
 		}
+		return "STASH_APPLY"
+	case ModeStdin:
+		return "SELECT"
 	}
+	return ""
 }
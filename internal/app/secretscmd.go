@@ -0,0 +1,50 @@
+package app
+
+import "github.com/jedipunkz/fuzz.fish/internal/history"
+
+// secretsParser returns a parser of the fish history that finds secrets with
+// the configured rules, even when they are not masked in the TUI.
+func secretsParser() *history.Parser {
+	s := settings.Secrets
+	s.Redact = true
+	p := history.NewParser()
+	// Configure has made sure the rules compile.
+	p.Redactor, _ = s.Redactor()
+	return p
+}
+
+// SecretCommand is a history command in which secrets were found
+type SecretCommand struct {
+	history.Entry
+	// Typed is how many commands typed with different secrets are masked
+	// into Cmd.
+	Typed int
+}
+
+// SecretHistory returns the history commands in which secrets were found,
+// masked and newest first, for the secrets subcommand.
+func SecretHistory() ([]SecretCommand, error) {
+	p := secretsParser()
+	var found []SecretCommand
+	var cmds []string
+	for _, e := range p.Parse() {
+		if len(e.Secrets) > 0 {
+			found = append(found, SecretCommand{Entry: e})
+			cmds = append(cmds, e.Cmd)
+		}
+	}
+	revealed, err := p.Reveal(cmds)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range revealed {
+		found[i].Typed = len(r)
+	}
+	return found, nil
+}
+
+// DeleteHistory deletes every entry of the commands cmds, as SecretHistory
+// returns them, from the history file and returns how many it removed.
+func DeleteHistory(cmds []string) (int, error) {
+	return secretsParser().Delete(cmds)
+}
@@ -1,25 +1,44 @@
 package app
 
 import (
+	"context"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/atotto/clipboard"
+	"charm.land/bubbles/v2/spinner"
 	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/jedipunkz/fuzz.fish/internal/config"
 	"github.com/jedipunkz/fuzz.fish/internal/files"
 	"github.com/jedipunkz/fuzz.fish/internal/git"
 	"github.com/jedipunkz/fuzz.fish/internal/history"
+	"github.com/jedipunkz/fuzz.fish/internal/ui"
 )
 
-// Update handles messages and updates the model
+// Update handles messages and updates the model. A preview requested by
+// updatePreview while handling msg is rendered by a command batched with the
+// one returned by update.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m, cmd := m.update(msg)
+	if m.previewCmd != nil {
+		cmd = tea.Batch(cmd, m.previewCmd)
+		m.previewCmd = nil
+	}
+	return m, cmd
+}
+
+func (m model) update(msg tea.Msg) (model, tea.Cmd) {
 	var cmd tea.Cmd
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
 	case historyLoadedMsg:
 		m.historyEntries = msg.entries
+		m.cwd, m.gitRoot = msg.cwd, msg.gitRoot
 		if m.mode == ModeHistory {
 			m.loading = false
 			m.loadItemsForMode()
@@ -31,70 +50,302 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.gitBranches = msg.branches
 		if m.mode == ModeGitBranch {
 			m.loading = false
+			if msg.err != nil {
+				m.statusMsg = "⚠ " + msg.err.Error()
+			}
+			m.resetPreview()
 			m.loadItemsForMode()
 			m.updateFilter(m.input.Value())
 		}
+		if len(msg.branches) == 0 {
+			return m, nil
+		}
+		return m, loadBranchDetailsCmd(msg.branches)
+
+	case branchDetailsLoadedMsg:
+		// The list may have been reloaded (e.g. after a rename or delete) while
+		// the details were loading, so they are matched up by name, and those
+		// of branches that are gone or have moved on are dropped.
+		type ref struct {
+			name   string
+			remote bool
+		}
+		details := make(map[ref]git.Branch, len(msg.branches))
+		for _, b := range msg.branches {
+			details[ref{b.Name, b.IsRemote}] = b
+		}
+		branches := slices.Clone(m.gitBranches)
+		for i, b := range branches {
+			if d, ok := details[ref{b.Name, b.IsRemote}]; ok && d.LastCommit == b.LastCommit {
+				d.IsCurrent = b.IsCurrent
+				branches[i] = d
+			}
+		}
+		git.SortByRecency(branches)
+		m.gitBranches = branches
+		if m.mode == ModeGitBranch {
+			// Previews rendered before the metadata arrived only show the hash.
+			m.resetPreview()
+			m.refreshItems()
+		}
 		return m, nil
 
-	case filesLoadedMsg:
-		m.fileEntries = msg.entries
+	case filesBatchMsg:
+		// Batches of a collection cancelled by a mode switch may still arrive.
+		if msg.gen != m.filesGen {
+			return m, nil
+		}
+		start := len(m.fileEntries)
+		m.fileEntries = append(m.fileEntries, msg.entries...)
+		if msg.done {
+			m.filesDone = true
+			m.filesCancel = nil
+		}
 		if m.mode == ModeFiles {
+			if len(m.fileEntries) > 0 || msg.done {
+				m.loading = false
+			}
+			// Only the new entries are matched, so a large tree does not
+			// take longer to rank with every batch.
+			items := make([]Item, len(msg.entries))
+			for i := range msg.entries {
+				items[i] = fileItem(start+i, m.fileEntries[start+i])
+			}
+			m.appendItems(items)
+		}
+		if msg.done {
+			return m, nil
+		}
+		return m, waitForFilesCmd(msg.gen, msg.next)
+
+	case grepBatchMsg:
+		// Batches of a search superseded by a newer query may still arrive.
+		if msg.gen != m.grepGen {
+			return m, nil
+		}
+		start := len(m.grepMatches)
+		m.grepMatches = append(m.grepMatches, msg.matches...)
+		if msg.done {
+			m.grepCancel = nil
+		}
+		if m.mode == ModeGrep {
+			if len(m.grepMatches) > 0 || msg.done {
+				m.loading = false
+			}
+			items := make([]Item, len(msg.matches))
+			for i := range msg.matches {
+				items[i] = grepItem(start+i, m.grepMatches[start+i])
+			}
+			m.appendItems(items)
+		}
+		if msg.done {
+			return m, nil
+		}
+		return m, waitForGrepCmd(msg.gen, msg.next)
+
+	case stdinBatchMsg:
+		start := len(m.stdinLines)
+		m.stdinLines = append(m.stdinLines, msg.lines...)
+		m.stdinDone = msg.done
+		if len(m.stdinLines) > 0 || msg.done {
 			m.loading = false
-			m.loadItemsForMode()
-			m.updateFilter(m.input.Value())
 		}
-		return m, nil
+		items := make([]Item, len(msg.lines))
+		for i := range msg.lines {
+			items[i] = stdinItem(start+i, m.stdinLines[start+i])
+		}
+		m.appendItems(items)
+		if msg.err != nil {
+			// The list would otherwise look complete.
+			m.statusMsg = "⚠ Stopped reading stdin: " + msg.err.Error()
+		}
+		if msg.done {
+			return m, nil
+		}
+		return m, waitForStdinCmd(msg.next, m.stdinErr)
+
+	case spinner.TickMsg:
+		// The spinner stops once the file collection, content search or
+		// stdin input has ended.
+		if m.filesCancel == nil && m.grepCancel == nil && (m.mode != ModeStdin || m.stdinDone) {
+			return m, nil
+		}
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
 
 	case worktreesLoadedMsg:
 		m.worktrees = msg.worktrees
 		if m.mode == ModeWorktree {
 			m.loading = false
+			if msg.err != nil {
+				m.statusMsg = "⚠ " + msg.err.Error()
+			}
+			m.resetPreview()
 			m.loadItemsForMode()
 			m.updateFilter(m.input.Value())
 		}
+		if len(msg.worktrees) == 0 {
+			return m, nil
+		}
+		return m, loadWorktreeDetailsCmd(msg.worktrees)
+
+	case worktreeDetailsLoadedMsg:
+		// The list may have been reloaded (e.g. after a removal) while the
+		// details were loading, so they are matched up by path.
+		details := make(map[string]*git.WorktreeDetails, len(msg.worktrees))
+		for _, wt := range msg.worktrees {
+			details[wt.Path] = wt.Details
+		}
+		for i := range m.worktrees {
+			if d, ok := details[m.worktrees[i].Path]; ok {
+				m.worktrees[i].Details = d
+			}
+		}
+		if m.mode == ModeWorktree {
+			// Previews rendered before the details arrived show a placeholder.
+			m.resetPreview()
+			m.refreshItems()
+		}
 		return m, nil
 
-	case filterTickMsg:
-		if msg.query == m.pendingQuery {
-			m.updateFilter(msg.query)
+	case stashesLoadedMsg:
+		m.stashes = msg.stashes
+		if m.mode == ModeStash {
+			m.loading = false
+			if msg.err != nil {
+				m.statusMsg = "⚠ " + msg.err.Error()
+			}
+			m.loadItemsForMode()
+			m.updateFilter(m.input.Value())
 		}
 		return m, nil
 
-	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
-		m.ready = true
+	case statusLoadedMsg:
+		m.gitStatus = msg.status
+		if msg.err != nil {
+			m.statusMsg = "⚠ " + msg.err.Error()
+		}
+		if m.mode == ModeStatus {
+			m.loading = false
+			// Staging changes a file's status and so its preview.
+			m.resetPreview()
+			m.refreshItems()
+		}
+		return m, nil
 
-		// Input box: top border (1) + input line (1) + bottom border (1) = 3
-		inputHeight := 3
-		mainHeight := msg.Height - inputHeight
-		if mainHeight < 0 {
-			mainHeight = 0
+	case historyDeletedMsg:
+		if msg.err != nil {
+			// Show the history as it still is.
+			m.statusMsg = "⚠ Could not delete from the history: " + msg.err.Error()
+			return m, loadHistoryCmd()
 		}
-		// Subtract top/bottom borders from main panes
-		m.mainHeight = mainHeight - 2
-		if m.mainHeight < 0 {
-			m.mainHeight = 0
+		m.statusMsg = "Deleted " + strconv.Itoa(msg.removed) + " history entries"
+		return m, nil
+
+	case gitOpDoneMsg:
+		if msg.err != nil {
+			m.statusMsg = "⚠ " + msg.err.Error()
+		} else {
+			m.statusMsg = msg.done
 		}
+		return m, msg.reload
 
-		// 60% split for list, 40% for preview
-		listWidth := int(float64(msg.Width) * 0.6)
-		// Subtract left/right borders from list width
-		m.listWidth = listWidth - 2
-		if m.listWidth < 0 {
-			m.listWidth = 0
+	case branchCreatedMsg:
+		if msg.err != nil {
+			m.statusMsg = "⚠ " + msg.err.Error()
+			return m, nil
 		}
+		// Switch to the new branch through the usual BRANCH: result.
+		m.choices = []string{msg.name}
+		m.quitting = true
+		return m, tea.Quit
 
-		// Preview width: remaining space minus borders
-		previewWidth := msg.Width - listWidth - 2
-		if previewWidth < 0 {
-			previewWidth = 0
+	case historyCountedMsg:
+		if msg.err != nil {
+			m.statusMsg = "⚠ " + msg.err.Error()
+			return m, nil
 		}
+		m.confirmDeleteHistory(msg.cmds, msg.typed)
+		return m, nil
 
-		m.viewport.SetWidth(previewWidth)
-		m.viewport.SetHeight(m.mainHeight)
+	case branchMergeCheckedMsg:
+		if msg.err != nil {
+			m.statusMsg = "⚠ " + msg.err.Error()
+			return m, nil
+		}
+		m.confirmDeleteBranches(msg.names, msg.unmerged)
+		return m, nil
+
+	case worktreeCreatedMsg:
+		if msg.err != nil {
+			m.statusMsg = "⚠ " + msg.err.Error()
+			return m, nil
+		}
+		// Change into the new worktree through the usual DIR: result.
+		m.choices = []string{msg.path}
+		m.choiceAction = "worktree"
+		m.quitting = true
+		return m, tea.Quit
+
+	case worktreeChangesCheckedMsg:
+		if msg.err != nil {
+			m.statusMsg = "⚠ " + msg.err.Error()
+			return m, nil
+		}
+		m.confirmRemoveWorktrees(msg.worktrees, msg.changes)
+		return m, nil
+
+	case commitsLoadedMsg:
+		// A reload for the other scope may still be in flight after a toggle.
+		if msg.all != m.commitsAll {
+			return m, nil
+		}
+		m.commits = msg.commits
+		if m.mode == ModeCommit {
+			m.loading = false
+			if msg.err != nil {
+				m.statusMsg = "⚠ " + msg.err.Error()
+			}
+			m.loadItemsForMode()
+			m.updateFilter(m.input.Value())
+		}
+		return m, nil
 
-		// Recalculate offset to keep cursor at the bottom of the view
+	case previewLoadedMsg:
+		// A cancelled render was superseded by another item's preview, and a
+		// killed git command may have left it incomplete.
+		if msg.ctx.Err() != nil {
+			return m, nil
+		}
+		m.previewCancel = nil
+		m.previewCache[msg.cacheKey] = msg.content
+		if msg.key == m.lastPreviewKey {
+			m.viewport.SetContent(msg.content)
+		}
+		return m, nil
+
+	case filterTickMsg:
+		if msg.query != m.pendingQuery {
+			return m, nil
+		}
+		if m.mode == ModeGrep {
+			// The query is searched for in file contents, so the hits of the
+			// previous query are replaced rather than filtered.
+			m.clearItems()
+			m.resetPreview()
+			return m, m.startGrep(msg.query)
+		}
+		m.updateFilter(msg.query)
+		return m, nil
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.ready = true
+		m.layout()
+
+		// Recalculate offset to keep the cursor in view at the end the list
+		// starts from
 		if len(m.filtered) > 0 {
 			m.offset = m.cursor - m.mainHeight + 1
 			if m.offset < 0 {
@@ -109,79 +360,29 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Clear status message on any key press
 		m.statusMsg = ""
 
-		switch msg.String() {
-		case "enter":
-			if len(m.filtered) > 0 {
-				m.selectItem()
-				m.quitting = true
-				return m, tea.Quit
-			}
-		case "tab":
-			if len(m.filtered) > 0 {
-				m.completeSelectedItem()
+		// A pending question takes the key press as its answer.
+		if m.confirm != nil {
+			c := m.confirm
+			m.confirm = nil
+			if k := msg.String(); k == "y" || k == "Y" {
+				return m, c.onYes(&m)
 			}
+			m.statusMsg = "Cancelled"
 			return m, nil
-		case "ctrl+c", "esc":
-			m.quitting = true
-			return m, tea.Quit
-		case "ctrl+y":
-			if len(m.filtered) > 0 {
-				_ = clipboard.WriteAll(m.filtered[m.cursor].Text)
-				m.quitting = true
-				return m, tea.Quit
-			}
-		case "ctrl+g":
-			if m.mode == ModeGitBranch {
-				// In GitBranch mode: pull current branch or show warning
-				if len(m.filtered) > 0 && m.filtered[m.cursor].IsCurrent {
-					branch := m.filtered[m.cursor].Original.(git.Branch)
-					res := branch.Name
-					m.choice = &res
-					m.fetchBranch = true
-					m.quitting = true
-					return m, tea.Quit
-				}
-				m.statusMsg = "⚠ Select current branch to pull"
-				return m, nil
-			}
-			cmd = m.switchToGitBranchMode()
-			return m, cmd
-		case "ctrl+s":
-			// Switch to Files mode
-			cmd = m.switchToFilesMode()
-			return m, cmd
-		case "ctrl+w":
-			// Switch to Worktree mode
-			cmd = m.switchToWorktreeMode()
-			return m, cmd
-		case "ctrl+r":
-			// Switch to History mode
-			cmd = m.switchToHistoryMode()
-			return m, cmd
-		case "down", "ctrl+n":
-			if len(m.filtered) > 0 {
-				m.cursor++
-				if m.cursor >= len(m.filtered) {
-					m.cursor = len(m.filtered) - 1
-				}
-				if m.cursor >= m.offset+m.mainHeight {
-					m.offset = m.cursor - m.mainHeight + 1
-				}
-				m.updatePreview()
-			}
+		}
+		if m.prompt != nil {
+			return m, m.updatePrompt(msg)
+		}
+		// Any key closes the help.
+		if m.showHelp {
+			m.showHelp = false
 			return m, nil
-		case "up", "ctrl+p":
-			if len(m.filtered) > 0 {
-				m.cursor--
-				if m.cursor < 0 {
-					m.cursor = 0
-				}
-				if m.cursor < m.offset {
-					m.offset = m.cursor
-				}
-				m.updatePreview()
+		}
+
+		if a := actionFor(m.mode, msg.String()); a != nil {
+			if cmd, handled := a.run(&m); handled {
+				return m, cmd
 			}
-			return m, nil
 		}
 	}
 
@@ -192,136 +393,416 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	newValue := m.input.Value()
 	if oldValue != newValue {
 		m.pendingQuery = newValue
-		cmds = append(cmds, tea.Tick(30*time.Millisecond, func(t time.Time) tea.Msg {
+		// A content search reads every file, so wait for a pause in typing.
+		delay := 30 * time.Millisecond
+		if m.mode == ModeGrep {
+			delay = 150 * time.Millisecond
+		}
+		cmds = append(cmds, tea.Tick(delay, func(t time.Time) tea.Msg {
 			return filterTickMsg{query: newValue}
 		}))
 	}
 
-	m.viewport, cmd = m.viewport.Update(msg)
-	cmds = append(cmds, cmd)
+	// Keys scroll the preview through actions only; the viewport's own key
+	// map would scroll it while a query is typed.
+	if _, ok := msg.(tea.KeyPressMsg); !ok {
+		m.viewport, cmd = m.viewport.Update(msg)
+		cmds = append(cmds, cmd)
+	}
 
 	return m, tea.Batch(cmds...)
 }
 
 // switchToGitBranchMode switches to git branch mode (Ctrl+G)
 func (m *model) switchToGitBranchMode() tea.Cmd {
-	if m.mode == ModeGitBranch {
-		return nil
+	return m.switchMode(ModeGitBranch, len(m.gitBranches) > 0, loadBranchesCmd)
+}
+
+// switchToHistoryMode switches directly to history mode (Ctrl+R)
+func (m *model) switchToHistoryMode() tea.Cmd {
+	// The initial history load may still be in flight, in which case its
+	// result no longer reaches this mode on its own, so it is reloaded.
+	return m.switchMode(ModeHistory, len(m.historyEntries) > 0, loadHistoryCmd)
+}
+
+// switchToFilesMode switches to files mode (Ctrl+S). Files are collected
+// again when an earlier collection was cancelled before it finished.
+func (m *model) switchToFilesMode() tea.Cmd {
+	return m.switchMode(ModeFiles, m.filesDone, m.startFileStream)
+}
+
+// switchToGrepMode switches to content search mode (Ctrl+F). Nothing is
+// searched until a query is typed.
+func (m *model) switchToGrepMode() tea.Cmd {
+	return m.switchMode(ModeGrep, true, nil)
+}
+
+// switchToWorktreeMode switches to git worktree mode (Ctrl+W)
+func (m *model) switchToWorktreeMode() tea.Cmd {
+	return m.switchMode(ModeWorktree, len(m.worktrees) > 0, loadWorktreesCmd)
+}
+
+// switchToCommitMode switches to git commit log mode (Ctrl+L)
+func (m *model) switchToCommitMode() tea.Cmd {
+	all := m.commitsAll
+	return m.switchMode(ModeCommit, len(m.commits) > 0, func() tea.Cmd { return loadCommitsCmd(all) })
+}
+
+// switchToStashMode switches to git stash mode (Ctrl+T)
+func (m *model) switchToStashMode() tea.Cmd {
+	return m.switchMode(ModeStash, len(m.stashes) > 0, loadStashesCmd)
+}
+
+// switchToStatusMode switches to git status mode (Ctrl+O). The status is
+// reloaded every time, since it changes as soon as a file is edited.
+func (m *model) switchToStatusMode() tea.Cmd {
+	return m.switchMode(ModeStatus, false, loadStatusCmd)
+}
+
+// deleteSelected starts deleting the selected item in modes that support it,
+// always behind a confirmation.
+func (m *model) deleteSelected() tea.Cmd {
+	switch m.mode {
+	case ModeHistory:
+		items := m.selectedItems()
+		cmds := make([]string, len(items))
+		for i, item := range items {
+			cmds[i] = item.Text
+		}
+		// A masked command may stand for several, so the prompt counts
+		// them first.
+		if slices.ContainsFunc(items, hasSecrets) {
+			return countHistoryCmd(cmds)
+		}
+		m.confirmDeleteHistory(cmds, len(cmds))
+	case ModeStash:
+		m.confirmDropStash()
+	case ModeGitBranch:
+		items := m.selectedItems()
+		names := make([]string, 0, len(items))
+		for _, item := range items {
+			branch := item.Original.(git.Branch)
+			switch {
+			case branch.IsRemote:
+				m.statusMsg = "⚠ Remote branches cannot be deleted here: " + branch.Name
+				return nil
+			case branch.IsCurrent:
+				m.statusMsg = "⚠ Cannot delete the current branch " + branch.Name
+				return nil
+			}
+			names = append(names, branch.Name)
+		}
+		// The prompt warns about unmerged commits, so check that first.
+		return checkBranchesMergedCmd(names)
+	case ModeWorktree:
+		items := m.selectedItems()
+		worktrees := make([]git.Worktree, 0, len(items))
+		check := false
+		for _, item := range items {
+			wt := item.Original.(git.Worktree)
+			switch {
+			case wt.IsCurrent:
+				m.statusMsg = "⚠ Cannot remove the current worktree " + wt.Path
+				return nil
+			case wt.Locked:
+				msg := "⚠ " + wt.Path + " is locked"
+				if wt.LockReason != "" {
+					msg += ": " + wt.LockReason
+				}
+				m.statusMsg = msg
+				return nil
+			}
+			worktrees = append(worktrees, wt)
+			check = check || !wt.Prunable
+		}
+		if !check {
+			// Their directories are gone, so there is nothing to check or lose.
+			m.confirmRemoveWorktrees(worktrees, nil)
+			return nil
+		}
+		// The prompt warns about uncommitted changes, so count them first.
+		return checkWorktreeChangesCmd(worktrees)
 	}
+	return nil
+}
 
-	m.mode = ModeGitBranch
-	m.input.SetValue("")
-	m.updatePlaceholder()
-	m.previewCache = make(map[string]string)
-	m.lastPreviewKey = ""
+// confirmRemoveWorktrees asks before removing worktrees, warning about the
+// ones with uncommitted changes, counted by path in changes. Dirty worktrees
+// are removed with force once confirmed.
+func (m *model) confirmRemoveWorktrees(worktrees []git.Worktree, changes map[string]int) {
+	var dirty []string
+	for _, wt := range worktrees {
+		if changes[wt.Path] > 0 {
+			dirty = append(dirty, wt.Path)
+		}
+	}
+	var prompt string
+	switch {
+	case len(worktrees) == 1 && len(dirty) == 0:
+		prompt = "Remove worktree " + worktrees[0].Path + "? (y/n)"
+	case len(worktrees) == 1:
+		prompt = "⚠ " + dirty[0] + " has " + strconv.Itoa(changes[dirty[0]]) + " uncommitted change(s). Remove anyway? (y/n)"
+	case len(dirty) == 0:
+		prompt = "Remove " + strconv.Itoa(len(worktrees)) + " worktrees? (y/n)"
+	default:
+		prompt = "⚠ Uncommitted changes in " + strings.Join(dirty, ", ") + ". Remove " + strconv.Itoa(len(worktrees)) + " worktrees anyway? (y/n)"
+	}
+	m.confirm = &confirmation{
+		prompt: prompt,
+		onYes: func(m *model) tea.Cmd {
+			m.marked = nil
+			return removeWorktreesCmd(worktrees, changes)
+		},
+	}
+}
 
-	if len(m.gitBranches) > 0 {
-		m.loadItemsForMode()
-		m.updateFilter("")
-		m.resetCursorToBottom()
-		m.updatePreview()
+// pruneWorktrees prunes the worktrees marked as prunable, if there are any.
+func (m *model) pruneWorktrees() tea.Cmd {
+	stale := 0
+	for _, wt := range m.worktrees {
+		if wt.Prunable {
+			stale++
+		}
+	}
+	if stale == 0 {
+		m.statusMsg = "No stale worktrees to prune"
 		return nil
 	}
+	return pruneWorktreesCmd(stale)
+}
 
-	// Async load branches
-	m.loading = true
-	m.filtered = nil
-	m.allItems = nil
-	m.allItemsStr = nil
-	m.cursor = 0
-	m.offset = 0
-	return loadBranchesCmd()
+// confirmCreateBranch asks before creating branch name from base (HEAD when
+// empty) and switching to it.
+func (m *model) confirmCreateBranch(name, base string) {
+	from := base
+	if from == "" {
+		from = "HEAD"
+	}
+	m.confirm = &confirmation{
+		prompt: "Create branch " + name + " from " + from + "? (y/n)",
+		onYes: func(m *model) tea.Cmd {
+			return createBranchCmd(name, base)
+		},
+	}
 }
 
-// switchToHistoryMode switches directly to history mode (Ctrl+R)
-func (m *model) switchToHistoryMode() tea.Cmd {
-	if m.mode == ModeHistory {
-		return nil
+// startRenameBranch prompts for a new name for the selected local branch.
+func (m *model) startRenameBranch() {
+	branch := m.filtered[m.cursor].Original.(git.Branch)
+	if branch.IsRemote {
+		m.statusMsg = "⚠ Remote branches cannot be renamed here"
+		return
 	}
+	m.startPrompt("Rename "+branch.Name+" to: ", branch.Name, func(m *model, name string) tea.Cmd {
+		if name == branch.Name {
+			return nil
+		}
+		return renameBranchCmd(branch.Name, name)
+	})
+}
 
-	m.mode = ModeHistory
-	m.input.SetValue("")
-	m.updatePlaceholder()
-	m.previewCache = make(map[string]string)
-	m.lastPreviewKey = ""
+// confirmDeleteBranches asks before deleting local branches, warning about
+// the ones with commits that are not merged into HEAD. Unmerged branches are
+// deleted with force once confirmed.
+func (m *model) confirmDeleteBranches(names, unmerged []string) {
+	var prompt string
+	switch {
+	case len(names) == 1 && len(unmerged) == 0:
+		prompt = "Delete branch " + names[0] + "? (y/n)"
+	case len(names) == 1:
+		prompt = "⚠ " + names[0] + " is not merged into HEAD. Delete anyway? (y/n)"
+	case len(unmerged) == 0:
+		prompt = "Delete " + strconv.Itoa(len(names)) + " branches? (y/n)"
+	default:
+		prompt = "⚠ " + strings.Join(unmerged, ", ") + " not merged into HEAD. Delete " + strconv.Itoa(len(names)) + " branches anyway? (y/n)"
+	}
+	m.confirm = &confirmation{
+		prompt: prompt,
+		onYes: func(m *model) tea.Cmd {
+			m.marked = nil
+			return deleteBranchesCmd(names, unmerged)
+		},
+	}
+}
 
-	if len(m.historyEntries) > 0 {
-		m.loadItemsForMode()
-		m.updateFilter("")
-		m.resetCursorToBottom()
-		m.updatePreview()
+// startPrompt turns the input box into a text prompt labelled label and
+// pre-filled with value. The search query is restored when the prompt ends.
+func (m *model) startPrompt(label, value string, onSubmit func(m *model, value string) tea.Cmd) {
+	m.prompt = &textPrompt{
+		onSubmit:    onSubmit,
+		savedQuery:  m.input.Value(),
+		savedPrompt: m.input.Prompt,
+	}
+	m.input.Prompt = label
+	m.input.SetValue(value)
+	m.input.CursorEnd()
+}
+
+// updatePrompt handles a key press while a text prompt is active: enter
+// submits a non-empty value, esc cancels, other keys edit the value.
+func (m *model) updatePrompt(msg tea.KeyPressMsg) tea.Cmd {
+	p := m.prompt
+	switch msg.String() {
+	case "enter":
+		value := strings.TrimSpace(m.input.Value())
+		m.endPrompt()
+		if value == "" {
+			return nil
+		}
+		return p.onSubmit(m, value)
+	case "esc", "ctrl+c":
+		m.endPrompt()
+		m.statusMsg = "Cancelled"
 		return nil
 	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return cmd
+}
 
-	// Async load history: the initial load may still be in flight, in which
-	// case its result no longer reaches this mode on its own.
-	m.loading = true
-	m.filtered = nil
-	m.allItems = nil
-	m.allItemsStr = nil
-	m.cursor = 0
-	m.offset = 0
-	return loadHistoryCmd()
+// endPrompt restores the input box to the search query it held before the
+// prompt started.
+func (m *model) endPrompt() {
+	m.input.Prompt = m.prompt.savedPrompt
+	m.input.SetValue(m.prompt.savedQuery)
+	m.input.CursorEnd()
+	m.prompt = nil
 }
 
-// switchToFilesMode switches to files mode (Ctrl+S)
-func (m *model) switchToFilesMode() tea.Cmd {
-	if m.mode == ModeFiles {
-		return nil
+// confirmDeleteHistory asks before deleting the listed commands cmds from
+// the history, every time they were run, and the typed commands they stand
+// for. They leave the list at once; the file is rewritten in the background.
+func (m *model) confirmDeleteHistory(cmds []string, typed int) {
+	prompt := "Delete " + strconv.Itoa(typed) + " commands from the history? (y/n)"
+	if len(cmds) == 1 {
+		cmd := strconv.Quote(ansi.Truncate(strings.ReplaceAll(cmds[0], "\n", " "), 40, "…"))
+		prompt = "Delete " + cmd + " from the history? (y/n)"
+		if typed > 1 {
+			prompt = "Delete the " + strconv.Itoa(typed) + " commands masked as " + cmd + " from the history? (y/n)"
+		}
+	}
+	m.confirm = &confirmation{
+		prompt: prompt,
+		onYes: func(m *model) tea.Cmd {
+			m.historyEntries = slices.DeleteFunc(slices.Clone(m.historyEntries), func(e history.Entry) bool {
+				return slices.Contains(cmds, e.Cmd)
+			})
+			m.marked = nil
+			m.refreshItems()
+			return deleteHistoryCmd(cmds)
+		},
 	}
+}
 
-	m.mode = ModeFiles
-	m.input.SetValue("")
-	m.updatePlaceholder()
-	m.previewCache = make(map[string]string)
-	m.lastPreviewKey = ""
+// confirmDropStash asks before dropping the selected stashes, since a
+// dropped stash can only be recovered from the reflog by hand.
+func (m *model) confirmDropStash() {
+	items := m.selectedItems()
+	prompt := "Drop " + strconv.Itoa(len(items)) + " stashes? (y/n)"
+	if len(items) == 1 {
+		stash, ok := items[0].Original.(git.Stash)
+		if !ok {
+			return
+		}
+		prompt = "Drop " + stash.Ref + "? (y/n)"
+	}
+	m.confirm = &confirmation{
+		prompt: prompt,
+		onYes: func(m *model) tea.Cmd {
+			m.selectItem()
+			m.choiceAction = "drop"
+			m.quitting = true
+			return tea.Quit
+		},
+	}
+}
 
-	if len(m.fileEntries) > 0 {
-		m.loadItemsForMode()
-		m.updateFilter("")
-		m.resetCursorToBottom()
-		m.updatePreview()
-		return nil
+// toggleCommitScope switches the commit log between the current branch and
+// all refs, reloading it for the new scope.
+func (m *model) toggleCommitScope() tea.Cmd {
+	m.commitsAll = !m.commitsAll
+	m.commits = nil
+	m.clearItems()
+	if m.commitsAll {
+		m.statusMsg = "Commits: all refs"
+	} else {
+		m.statusMsg = "Commits: current branch"
 	}
+	return loadCommitsCmd(m.commitsAll)
+}
 
-	// Async load files
-	m.loading = true
-	m.filtered = nil
-	m.allItems = nil
-	m.allItemsStr = nil
-	m.cursor = 0
-	m.offset = 0
-	return loadFilesCmd()
+// cycleHistoryScope moves history mode on to the next scope: all commands,
+// those run in the current directory, then those run anywhere in its git
+// working tree, which is skipped outside of one.
+func (m *model) cycleHistoryScope() {
+	m.historyScope = (m.historyScope + 1) % 3
+	if m.historyScope == scopeRepo && m.gitRoot == "" {
+		m.historyScope = scopeAll
+	}
+	m.loadItemsForMode()
+	m.updateFilter(m.input.Value())
 }
 
-// switchToWorktreeMode switches to git worktree mode (Ctrl+W)
-func (m *model) switchToWorktreeMode() tea.Cmd {
-	if m.mode == ModeWorktree {
+// toggleShowAllFiles switches files mode between honouring ignore files and
+// listing everything, hidden files included, and collects the files again.
+func (m *model) toggleShowAllFiles() tea.Cmd {
+	m.filesShowAll = !m.filesShowAll
+	m.clearItems()
+	m.resetPreview()
+	if m.filesShowAll {
+		m.statusMsg = "Files: showing hidden and ignored files"
+	} else {
+		m.statusMsg = "Files: honouring ignore files"
+	}
+	return m.startFileStream()
+}
+
+// switchMode switches to mode, showing its already loaded data when loaded is
+// true and starting the async load otherwise.
+func (m *model) switchMode(mode SearchMode, loaded bool, load func() tea.Cmd) tea.Cmd {
+	// A stdin session only picks from the piped lines.
+	if m.mode == mode || m.mode == ModeStdin {
 		return nil
 	}
 
-	m.mode = ModeWorktree
+	// Walking the tree is only worth it while files mode is shown.
+	if m.mode == ModeFiles {
+		m.cancelFileStream()
+	}
+	// The query a content search ran for is cleared below, so its hits go too.
+	if m.mode == ModeGrep {
+		m.cancelGrep()
+		m.grepMatches = nil
+		m.grepGen++
+	}
+	m.mode = mode
+	m.marked = nil
 	m.input.SetValue("")
 	m.updatePlaceholder()
-	m.previewCache = make(map[string]string)
-	m.lastPreviewKey = ""
+	m.resetPreview()
 
-	if len(m.worktrees) > 0 {
+	if loaded {
+		m.loading = false
 		m.loadItemsForMode()
 		m.updateFilter("")
-		m.resetCursorToBottom()
+		m.resetCursor()
 		m.updatePreview()
 		return nil
 	}
 
-	// Async load worktrees
+	m.clearItems()
+	return load()
+}
+
+// clearItems empties the list while the current mode's data is (re)loading.
+func (m *model) clearItems() {
 	m.loading = true
+	m.marked = nil
 	m.filtered = nil
 	m.allItems = nil
 	m.allItemsStr = nil
 	m.cursor = 0
 	m.offset = 0
-	return loadWorktreesCmd()
 }
 
 // updatePlaceholder updates the input placeholder based on current mode
@@ -335,6 +816,16 @@ func (m *model) updatePlaceholder() {
 		m.input.Placeholder = ""
 	case ModeWorktree:
 		m.input.Placeholder = ""
+	case ModeCommit:
+		m.input.Placeholder = ""
+	case ModeStash:
+		m.input.Placeholder = ""
+	case ModeStatus:
+		m.input.Placeholder = ""
+	case ModeGrep:
+		m.input.Placeholder = ""
+	case ModeStdin:
+		m.input.Placeholder = ""
 	}
 }
 
@@ -346,8 +837,60 @@ func (m *model) completeSelectedItem() {
 	m.updateFilter(text)
 }
 
-// resetCursorToBottom resets the cursor to the bottom of the list
-func (m *model) resetCursorToBottom() {
+// copyText returns the text ctrl+y copies for item: the full hash for
+// commits, the commands as they were typed, one per line, for history, the
+// displayed text otherwise.
+func (m *model) copyText(item Item) string {
+	if c, ok := item.Original.(git.Commit); ok {
+		return c.Hash
+	}
+	if hasSecrets(item) {
+		return strings.Join(revealCommands([]string{item.Text}), "\n")
+	}
+	return item.Text
+}
+
+// refreshItems reloads the current mode's items and re-applies the query,
+// keeping the selection on the same item when it is still listed.
+func (m *model) refreshItems() {
+	var selected string
+	if len(m.filtered) > 0 {
+		selected = m.filtered[m.cursor].Text
+	}
+	m.loadItemsForMode()
+	m.updateFilter(m.input.Value())
+	if selected == "" {
+		return
+	}
+	for i, item := range m.filtered {
+		if item.Text == selected {
+			m.cursor = i
+			m.validateCursor()
+			m.updatePreview()
+			return
+		}
+	}
+}
+
+// moveCursor moves the cursor by delta items, staying on the list and
+// scrolling it to keep the cursor in view.
+func (m *model) moveCursor(delta int) {
+	if len(m.filtered) == 0 {
+		return
+	}
+	m.cursor = max(0, min(len(m.filtered)-1, m.cursor+delta))
+	if m.cursor >= m.offset+m.mainHeight {
+		m.offset = m.cursor - m.mainHeight + 1
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	m.updatePreview()
+}
+
+// resetCursor moves the cursor to the best match, which the list starts from:
+// at the bottom, or at the top when it is reversed.
+func (m *model) resetCursor() {
 	if len(m.filtered) > 0 {
 		m.cursor = len(m.filtered) - 1
 		m.offset = m.cursor - m.mainHeight + 1
@@ -360,7 +903,65 @@ func (m *model) resetCursorToBottom() {
 	}
 }
 
-// validateCursor ensures the cursor is within valid bounds
+// up returns the cursor step that moves it up the screen: the list is ranked
+// with the best match last, which is drawn at the bottom, or at the top when
+// the list is reversed.
+func (m *model) up() int {
+	if m.reverse {
+		return 1
+	}
+	return -1
+}
+
+// layout sizes the panes to the terminal: the list beside the preview, above
+// it when the preview is at the bottom or the terminal is narrower than
+// layout.stack_below, or alone when the preview is hidden.
+func (m *model) layout() {
+	l := settings.Layout
+
+	// Input box: top border (1) + input line (1) + bottom border (1) = 3
+	inputHeight := 3
+	panesHeight := max(0, m.height-inputHeight)
+
+	m.previewBelow = l.Preview == config.PreviewBottom || (l.StackBelow > 0 && m.width < l.StackBelow)
+	listWidth, listHeight := m.width, panesHeight
+	previewWidth, previewHeight := 0, 0
+	switch {
+	case m.previewHidden:
+	case m.previewBelow:
+		previewWidth = m.width
+		previewHeight = panesHeight * l.PreviewHeight / 100
+		listHeight = panesHeight - previewHeight
+	default:
+		// The list takes its configured share (60% by default), the preview
+		// the rest
+		listWidth = m.width * l.ListWidth / 100
+		previewWidth = m.width - listWidth
+		previewHeight = panesHeight
+	}
+
+	// Subtract the borders of the boxes
+	m.listWidth = max(0, listWidth-2)
+	m.mainHeight = max(0, listHeight-2)
+	m.viewport.SetWidth(max(0, previewWidth-2))
+	m.viewport.SetHeight(max(0, previewHeight-2))
+}
+
+// togglePreview hides the preview, or shows it again.
+func (m *model) togglePreview() {
+	m.previewHidden = !m.previewHidden
+	m.layout()
+	m.validateCursor()
+	// The preview was not kept up to date while hidden, and a shown one is
+	// rendered for the new size.
+	m.cancelPreview()
+	m.lastPreviewKey = ""
+	m.updatePreview()
+}
+
+// validateCursor ensures the cursor is within valid bounds and in view, and
+// that the pane stays full while there are more items than fit in it, at
+// either end the list starts from.
 func (m *model) validateCursor() {
 	if len(m.filtered) == 0 {
 		m.cursor = 0
@@ -374,6 +975,9 @@ func (m *model) validateCursor() {
 		m.cursor = 0
 	}
 
+	if last := len(m.filtered) - m.mainHeight; m.offset > last {
+		m.offset = last
+	}
 	if m.offset < 0 {
 		m.offset = 0
 	}
@@ -395,9 +999,14 @@ func previewKey(mode SearchMode, item Item) string {
 	return strconv.Itoa(int(mode)) + "\x00" + strconv.Itoa(item.Index) + "\x00" + item.Text
 }
 
-// updatePreview updates the preview pane content
+// updatePreview updates the preview pane content. History and stdin previews
+// come from memory and are rendered right away. Other previews read files or
+// run git, so they are rendered in the background by previewCmd while a
+// placeholder is shown; moving to another item cancels a render still in
+// progress.
 func (m *model) updatePreview() {
-	if len(m.filtered) == 0 {
+	if len(m.filtered) == 0 || m.previewHidden {
+		m.cancelPreview()
 		m.viewport.SetContent("")
 		m.lastPreviewKey = ""
 		return
@@ -413,52 +1022,193 @@ func (m *model) updatePreview() {
 		return
 	}
 	m.lastPreviewKey = key
+	m.cancelPreview()
 
-	var content string
-	var cacheKey string
-
-	switch m.mode {
-	case ModeHistory:
+	width, height := m.viewport.Width(), m.viewport.Height()
+	if m.mode == ModeHistory {
 		entry := item.Original.(history.Entry)
-		content = entry.GeneratePreview(m.historyEntries, item.Index, m.viewport.Width(), m.viewport.Height())
+		m.viewport.SetContent(entry.GeneratePreview(m.historyEntries, item.Index, width, height))
+		return
+	}
+	if m.mode == ModeStdin {
+		// Lines cut off in the list are shown in full, wrapped.
+		m.viewport.SetContent(lipgloss.NewStyle().Width(width).Render(item.Original.(string)))
+		return
+	}
+
+	cacheKey, render := previewRenderer(m.mode, item, width, height)
+	if render == nil {
+		m.viewport.SetContent("")
+		return
+	}
+	if cached, ok := m.previewCache[cacheKey]; ok {
+		m.viewport.SetContent(cached)
+		return
+	}
+
+	m.viewport.SetContent(ui.InactiveContextStyle.Render("  Loading preview..."))
+	ctx, cancel := context.WithCancel(context.Background())
+	m.previewCancel = cancel
+	m.previewCmd = func() tea.Msg {
+		return previewLoadedMsg{ctx: ctx, key: key, cacheKey: cacheKey, content: render(ctx)}
+	}
+}
+
+// previewRenderer returns the key item's preview is cached under and a
+// function rendering it, or a nil function when the mode has no preview.
+func previewRenderer(mode SearchMode, item Item, width, height int) (string, func(ctx context.Context) string) {
+	switch mode {
 	case ModeGitBranch:
 		branch := item.Original.(git.Branch)
-		cacheKey = branch.Name
-		if cached, ok := m.previewCache[cacheKey]; ok {
-			content = cached
-		} else {
-			content = branch.GeneratePreview(m.viewport.Width(), m.viewport.Height())
-			m.previewCache[cacheKey] = content
+		return branch.Name, func(context.Context) string {
+			return branch.GeneratePreview(width, height)
 		}
 	case ModeFiles:
 		entry := item.Original.(files.Entry)
-		cacheKey = entry.Path
-		if cached, ok := m.previewCache[cacheKey]; ok {
-			content = cached
-		} else {
-			content = entry.GeneratePreview(m.viewport.Width(), m.viewport.Height())
-			m.previewCache[cacheKey] = content
+		return entry.Path, func(context.Context) string {
+			return entry.GeneratePreview(width, height)
 		}
 	case ModeWorktree:
 		wt := item.Original.(git.Worktree)
-		cacheKey = wt.Path
-		if cached, ok := m.previewCache[cacheKey]; ok {
-			content = cached
-		} else {
-			content = wt.GeneratePreview(m.viewport.Width(), m.viewport.Height())
-			m.previewCache[cacheKey] = content
+		return wt.Path, func(context.Context) string {
+			return wt.GeneratePreview(width, height)
+		}
+	case ModeCommit:
+		c := item.Original.(git.Commit)
+		return c.Hash, func(ctx context.Context) string {
+			return c.GeneratePreview(ctx, width, height)
+		}
+	case ModeStash:
+		stash := item.Original.(git.Stash)
+		return stash.Hash, func(ctx context.Context) string {
+			return stash.GeneratePreview(ctx, width, height)
+		}
+	case ModeStatus:
+		f := item.Original.(git.FileStatus)
+		return f.Code() + f.Path, func(ctx context.Context) string {
+			return f.GeneratePreview(ctx, width, height)
+		}
+	case ModeGrep:
+		match := item.Original.(files.Match)
+		return match.Path + ":" + strconv.Itoa(match.Line), func(context.Context) string {
+			return match.GeneratePreview(width, height)
 		}
 	}
-	m.viewport.SetContent(content)
+	return "", nil
 }
 
-// selectItem handles item selection
-func (m *model) selectItem() {
+// cancelPreview stops a background preview render still in progress.
+func (m *model) cancelPreview() {
+	if m.previewCancel != nil {
+		m.previewCancel()
+		m.previewCancel = nil
+	}
+	m.previewCmd = nil
+}
+
+// resetPreview drops cached previews, e.g. after the data they were rendered
+// from has changed, so the next updatePreview renders the selection again.
+func (m *model) resetPreview() {
+	m.cancelPreview()
+	m.previewCache = make(map[string]string)
+	m.lastPreviewKey = ""
+}
+
+// markKey identifies an item across reloads of the list: Index is its
+// position in the source slice, which only grows while files stream in.
+func markKey(item Item) string {
+	return strconv.Itoa(item.Index) + "\x00" + item.Text
+}
+
+// isMarked reports whether item is marked for multi-select.
+func (m *model) isMarked(item Item) bool {
+	key := markKey(item)
+	for _, marked := range m.marked {
+		if markKey(marked) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleMark marks the item under the cursor, or unmarks it, and moves the
+// cursor by step so several items can be marked in a row.
+func (m *model) toggleMark(step int) {
 	item := m.filtered[m.cursor]
+	key := markKey(item)
+	if i := slices.IndexFunc(m.marked, func(marked Item) bool { return markKey(marked) == key }); i >= 0 {
+		m.marked = slices.Delete(m.marked, i, i+1)
+	} else {
+		m.marked = append(m.marked, item)
+	}
+	m.moveCursor(step)
+}
+
+// selectedItems returns the items an action applies to: the marked items,
+// or the item under the cursor when none is marked.
+func (m *model) selectedItems() []Item {
+	if len(m.marked) > 0 {
+		return m.marked
+	}
+	return []Item{m.filtered[m.cursor]}
+}
+
+// selectWith chooses the selection for the secondary action (e.g. "pop" in
+// stash mode; empty for enter) and quits.
+func (m *model) selectWith(action string) tea.Cmd {
+	if len(m.filtered) == 0 && len(m.marked) == 0 {
+		return nil
+	}
+	m.selectItem()
+	m.choiceAction = action
+	m.quitting = true
+	return tea.Quit
+}
+
+// selectItem sets the choices to the values of the selected items
+func (m *model) selectItem() {
+	items := m.selectedItems()
+	if m.mode == ModeStash && len(items) > 1 {
+		// Popping or dropping a stash renumbers the ones after it, so the
+		// oldest (highest index) go first.
+		items = slices.Clone(items)
+		sort.Slice(items, func(i, j int) bool { return items[i].Index > items[j].Index })
+	}
+
+	m.choices = m.choices[:0]
+	m.choiceLines = nil
+	for _, item := range items {
+		if res, ok := m.choiceValue(item); ok {
+			m.choices = append(m.choices, res)
+			// Hits are opened at their line, which is kept apart from the
+			// path since a file name may end in ":N" itself.
+			if match, ok := item.Original.(files.Match); ok {
+				m.choiceLines = append(m.choiceLines, match.Line)
+			}
+		}
+	}
+	// Secrets are masked in the list, but the command line gets the
+	// commands as they were typed.
+	if m.mode == ModeHistory && slices.ContainsFunc(items, hasSecrets) {
+		m.choices = revealCommands(m.choices)
+	}
+	// A single directory is changed into; several paths are inserted.
+	if entry, ok := items[0].Original.(files.Entry); ok && len(items) == 1 {
+		m.choiceIsDir = entry.IsDir
+	}
+}
+
+// hasSecrets reports whether item is a history entry with masked secrets.
+func hasSecrets(item Item) bool {
+	e, ok := item.Original.(history.Entry)
+	return ok && len(e.Secrets) > 0
+}
+
+// choiceValue returns the value printed for item when it is chosen
+func (m *model) choiceValue(item Item) (string, bool) {
 	switch m.mode {
 	case ModeHistory:
-		res := item.Text
-		m.choice = &res
+		return item.Text, true
 	case ModeGitBranch:
 		branch := item.Original.(git.Branch)
 		res := branch.Name
@@ -468,19 +1218,34 @@ func (m *model) selectItem() {
 				res = parts[1]
 			}
 		}
-		m.choice = &res
+		return res, true
 	case ModeFiles:
-		entry, ok := item.Original.(files.Entry)
-		if ok {
-			res := entry.Path
-			m.choice = &res
-			m.choiceIsDir = entry.IsDir
+		if entry, ok := item.Original.(files.Entry); ok {
+			return entry.Path, true
 		}
 	case ModeWorktree:
-		wt, ok := item.Original.(git.Worktree)
-		if ok {
-			res := wt.Path
-			m.choice = &res
+		if wt, ok := item.Original.(git.Worktree); ok {
+			return wt.Path, true
+		}
+	case ModeCommit:
+		if c, ok := item.Original.(git.Commit); ok {
+			return c.Hash, true
+		}
+	case ModeStash:
+		if stash, ok := item.Original.(git.Stash); ok {
+			return stash.Ref, true
+		}
+	case ModeStatus:
+		// Text holds the path relative to the current directory.
+		return item.Text, true
+	case ModeGrep:
+		if match, ok := item.Original.(files.Match); ok {
+			return match.Path, true
+		}
+	case ModeStdin:
+		if line, ok := item.Original.(string); ok {
+			return line, true
 		}
 	}
+	return "", false
 }
@@ -0,0 +1,792 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"charm.land/bubbles/v2/textinput"
+	"charm.land/bubbles/v2/viewport"
+	tea "charm.land/bubbletea/v2"
+	"github.com/jedipunkz/fuzz.fish/internal/files"
+	"github.com/jedipunkz/fuzz.fish/internal/git"
+	"github.com/jedipunkz/fuzz.fish/internal/history"
+)
+
+// press sends a key press through Update and returns the resulting model.
+func press(t *testing.T, m model, key tea.Key) (model, tea.Cmd) {
+	t.Helper()
+	updated, cmd := m.Update(tea.KeyPressMsg(key))
+	got, ok := updated.(model)
+	if !ok {
+		t.Fatalf("Update() returned %T, want model", updated)
+	}
+	return got, cmd
+}
+
+func stashModel() model {
+	m := model{
+		mode:         ModeStash,
+		viewport:     viewport.New(),
+		previewCache: map[string]string{},
+		mainHeight:   10,
+		stashes: []git.Stash{
+			{Ref: "stash@{0}", Hash: "a", Message: "newest", Branch: "main"},
+			{Ref: "stash@{1}", Hash: "b", Message: "older", Branch: "main"},
+		},
+	}
+	m.loadItemsForMode()
+	m.filtered = append([]Item(nil), m.allItems...)
+	m.cursor = len(m.filtered) - 1
+	return m
+}
+
+func TestUpdate_StashDropAsksForConfirmation(t *testing.T) {
+	m, _ := press(t, stashModel(), tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	if m.confirm == nil {
+		t.Fatal("ctrl+x did not ask for confirmation")
+	}
+	if len(m.choices) != 0 {
+		t.Fatalf("choices = %q before confirming, want none", m.choices)
+	}
+
+	m, cmd := press(t, m, tea.Key{Code: 'y', Text: "y"})
+	if len(m.choices) != 1 || m.choices[0] != "stash@{0}" || m.choiceAction != "drop" {
+		t.Fatalf("after confirming, choices = %q action = %q, want stash@{0} drop", m.choices, m.choiceAction)
+	}
+	if cmd == nil {
+		t.Error("confirming did not quit")
+	}
+}
+
+func TestUpdate_StashDropCancelledByOtherKey(t *testing.T) {
+	m, _ := press(t, stashModel(), tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	m, _ = press(t, m, tea.Key{Code: 'n', Text: "n"})
+	if m.confirm != nil {
+		t.Error("confirmation still pending after answering n")
+	}
+	if len(m.choices) != 0 {
+		t.Errorf("choices = %q after cancelling, want none", m.choices)
+	}
+}
+
+func TestUpdate_StashPopUsesSecondaryAction(t *testing.T) {
+	m, _ := press(t, stashModel(), tea.Key{Code: tea.KeyEnter, Mod: tea.ModAlt})
+	if len(m.choices) != 1 || m.choices[0] != "stash@{0}" || m.choiceAction != "pop" {
+		t.Fatalf("alt+enter: choices = %q action = %q, want stash@{0} pop", m.choices, m.choiceAction)
+	}
+}
+
+func TestUpdate_GitLoadErrorsAreShown(t *testing.T) {
+	err := errors.New("not a git repository")
+	for mode, msg := range map[SearchMode]tea.Msg{
+		ModeStash:    stashesLoadedMsg{err: err},
+		ModeCommit:   commitsLoadedMsg{err: err},
+		ModeWorktree: worktreesLoadedMsg{err: err},
+	} {
+		m := stashModel()
+		m.mode, m.loading = mode, true
+		updated, _ := m.Update(msg)
+		if m = updated.(model); m.loading || !strings.Contains(m.statusMsg, err.Error()) {
+			t.Errorf("%v: loading = %v, statusMsg = %q; want the error shown", mode, m.loading, m.statusMsg)
+		}
+	}
+}
+
+func branchModel() model {
+	m := model{
+		mode:         ModeGitBranch,
+		input:        textinput.New(),
+		viewport:     viewport.New(),
+		previewCache: map[string]string{},
+		mainHeight:   10,
+		gitBranches: []git.Branch{
+			{Name: "main", IsCurrent: true},
+			{Name: "topic"},
+		},
+	}
+	m.loadItemsForMode()
+	m.updateFilter("")
+	// Update would run the preview render requested by updateFilter.
+	m.previewCmd = nil
+	return m
+}
+
+func TestUpdate_BranchEnterWithoutMatchOffersCreate(t *testing.T) {
+	m := branchModel()
+	m.input.SetValue("feature/new")
+	m.updateFilter("feature/new")
+	if len(m.filtered) != 0 {
+		t.Fatalf("query matched %d branches, want none", len(m.filtered))
+	}
+
+	m, _ = press(t, m, tea.Key{Code: tea.KeyEnter})
+	if m.confirm == nil || !strings.Contains(m.confirm.prompt, "feature/new") {
+		t.Fatalf("enter without matches: confirm = %+v, want a create prompt for feature/new", m.confirm)
+	}
+	if len(m.choices) != 0 {
+		t.Errorf("choices = %q before the branch exists, want none", m.choices)
+	}
+}
+
+func TestUpdate_BranchRenamePromptRestoresQuery(t *testing.T) {
+	m := branchModel()
+	m.input.SetValue("top")
+	m.updateFilter("top")
+
+	m, _ = press(t, m, tea.Key{Code: 'r', Mod: tea.ModAlt})
+	if m.prompt == nil {
+		t.Fatal("alt+r did not start the rename prompt")
+	}
+	if got := m.input.Value(); got != "topic" {
+		t.Errorf("rename prompt value = %q, want the current name %q", got, "topic")
+	}
+
+	m, _ = press(t, m, tea.Key{Code: tea.KeyEscape})
+	if m.prompt != nil {
+		t.Fatal("esc did not end the rename prompt")
+	}
+	if got := m.input.Value(); got != "top" {
+		t.Errorf("query after cancelling = %q, want %q", got, "top")
+	}
+}
+
+func TestUpdate_BranchDeleteRefusesCurrentBranch(t *testing.T) {
+	m := branchModel()
+	for i, item := range m.filtered {
+		if item.IsCurrent {
+			m.cursor = i
+		}
+	}
+
+	m, cmd := press(t, m, tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	if cmd != nil || m.confirm != nil {
+		t.Error("ctrl+x on the current branch started a deletion")
+	}
+	if m.statusMsg == "" {
+		t.Error("ctrl+x on the current branch showed no explanation")
+	}
+}
+
+func TestUpdate_UnmergedBranchDeleteWarns(t *testing.T) {
+	m := branchModel()
+	updated, _ := m.Update(branchMergeCheckedMsg{names: []string{"topic"}, unmerged: []string{"topic"}})
+	m = updated.(model)
+	if m.confirm == nil || !strings.Contains(m.confirm.prompt, "not merged") {
+		t.Fatalf("confirm = %+v, want an unmerged warning", m.confirm)
+	}
+}
+
+func TestUpdate_StaleBranchDetailsKeepTheReloadedList(t *testing.T) {
+	m := branchModel()
+	// Details loaded for main and topic arrive after topic was renamed.
+	stale := branchDetailsLoadedMsg{branches: []git.Branch{
+		{Name: "main", LastCommit: "aaa", Author: "Ann", CommitTimestamp: 100},
+		{Name: "topic", LastCommit: "bbb", Author: "Bob", CommitTimestamp: 200},
+	}}
+	updated, _ := m.Update(branchesLoadedMsg{branches: []git.Branch{
+		{Name: "main", IsCurrent: true, LastCommit: "aaa"},
+		{Name: "feature", LastCommit: "bbb"},
+	}})
+	m = updated.(model)
+	updated, _ = m.Update(stale)
+	m = updated.(model)
+
+	var names []string
+	for _, b := range m.gitBranches {
+		names = append(names, b.Name)
+	}
+	if !slices.Equal(names, []string{"main", "feature"}) {
+		t.Fatalf("branches = %q after stale details, want the reloaded main and feature", names)
+	}
+	if main := m.gitBranches[0]; main.Author != "Ann" || !main.IsCurrent {
+		t.Errorf("main = %+v, want its details and still current", main)
+	}
+	if m.gitBranches[1].Author != "" {
+		t.Errorf("feature = %+v, want no details of the old topic", m.gitBranches[1])
+	}
+}
+
+func worktreeModel() model {
+	m := model{
+		mode:         ModeWorktree,
+		viewport:     viewport.New(),
+		previewCache: map[string]string{},
+		mainHeight:   10,
+		worktrees: []git.Worktree{
+			{Path: "/repo/main", Branch: "main", IsCurrent: true},
+			{Path: "/repo/usb", Branch: "usb", Locked: true, LockReason: "usb disk"},
+			{Path: "/repo/gone", Branch: "gone", Prunable: true},
+		},
+	}
+	m.loadItemsForMode()
+	m.filtered = append([]Item(nil), m.allItems...)
+	m.cursor = len(m.filtered) - 1
+	return m
+}
+
+// selectWorktree moves the cursor to the worktree at path.
+func selectWorktree(t *testing.T, m model, path string) model {
+	t.Helper()
+	for i, item := range m.filtered {
+		if item.Text == path {
+			m.cursor = i
+			return m
+		}
+	}
+	t.Fatalf("worktree %s not listed", path)
+	return m
+}
+
+func TestUpdate_WorktreeRemoveRefusesCurrentAndLocked(t *testing.T) {
+	for path, want := range map[string]string{
+		"/repo/main": "current worktree",
+		"/repo/usb":  "locked: usb disk",
+	} {
+		m := selectWorktree(t, worktreeModel(), path)
+		m, cmd := press(t, m, tea.Key{Code: 'x', Mod: tea.ModCtrl})
+		if cmd != nil || m.confirm != nil {
+			t.Errorf("%s: ctrl+x started a removal", path)
+		}
+		if !strings.Contains(m.statusMsg, want) {
+			t.Errorf("%s: statusMsg = %q, want it to mention %q", path, m.statusMsg, want)
+		}
+	}
+}
+
+func TestUpdate_WorktreeRemoveWarnsAboutChanges(t *testing.T) {
+	m := selectWorktree(t, worktreeModel(), "/repo/gone")
+	wt := m.filtered[m.cursor].Original.(git.Worktree)
+	wt.Prunable = false
+	updated, _ := m.Update(worktreeChangesCheckedMsg{worktrees: []git.Worktree{wt}, changes: map[string]int{wt.Path: 3}})
+	m = updated.(model)
+	if m.confirm == nil || !strings.Contains(m.confirm.prompt, "3 uncommitted") {
+		t.Fatalf("confirm = %+v, want a warning about 3 uncommitted changes", m.confirm)
+	}
+}
+
+func TestUpdate_MarkedWorktreesAreRemovedTogether(t *testing.T) {
+	m := worktreeModel()
+	m.worktrees = append(m.worktrees, git.Worktree{Path: "/repo/topic", Branch: "topic"})
+	m.loadItemsForMode()
+	m.filtered = append([]Item(nil), m.allItems...)
+
+	// Mark /repo/gone and /repo/topic, leaving the cursor elsewhere.
+	for _, path := range []string{"/repo/gone", "/repo/topic"} {
+		m = selectWorktree(t, m, path)
+		m, _ = press(t, m, tea.Key{Code: tea.KeyTab})
+	}
+	m = selectWorktree(t, m, "/repo/usb")
+
+	m, cmd := press(t, m, tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	if cmd == nil {
+		t.Fatal("ctrl+x did not count the changes of the marked worktrees")
+	}
+	if strings.Contains(m.statusMsg, "locked") {
+		t.Fatalf("ctrl+x acted on the locked worktree under the cursor: %q", m.statusMsg)
+	}
+
+	var worktrees []git.Worktree
+	for _, item := range m.marked {
+		worktrees = append(worktrees, item.Original.(git.Worktree))
+	}
+	updated, _ := m.Update(worktreeChangesCheckedMsg{worktrees: worktrees, changes: map[string]int{"/repo/topic": 2}})
+	m = updated.(model)
+	if m.confirm == nil || !strings.Contains(m.confirm.prompt, "/repo/topic") || !strings.Contains(m.confirm.prompt, "2 worktrees") {
+		t.Fatalf("confirm = %+v, want a prompt for 2 worktrees warning about /repo/topic", m.confirm)
+	}
+
+	// A marked worktree that cannot be removed stops the whole removal.
+	m.confirm = nil
+	m = selectWorktree(t, m, "/repo/usb")
+	m, _ = press(t, m, tea.Key{Code: tea.KeyTab})
+	m, cmd = press(t, m, tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	if cmd != nil || m.confirm != nil || !strings.Contains(m.statusMsg, "locked") {
+		t.Errorf("ctrl+x with a locked worktree marked: statusMsg = %q, want a refusal", m.statusMsg)
+	}
+}
+
+func TestUpdate_WorktreePruneNeedsStaleEntries(t *testing.T) {
+	m := worktreeModel()
+	m.worktrees = m.worktrees[:2]
+	m, cmd := press(t, m, tea.Key{Code: 'p', Mod: tea.ModAlt})
+	if cmd != nil {
+		t.Error("alt+p pruned without stale worktrees")
+	}
+	if m.statusMsg == "" {
+		t.Error("alt+p gave no feedback without stale worktrees")
+	}
+}
+
+func TestUpdate_StalePreviewIsDiscarded(t *testing.T) {
+	m := branchModel()
+	m.viewport.SetWidth(40)
+	m.viewport.SetHeight(10)
+	m.resetPreview()
+	m.updatePreview()
+	first := m.previewCmd
+	if first == nil {
+		t.Fatal("updatePreview did not request a background render")
+	}
+	if !strings.Contains(m.viewport.View(), "Loading") {
+		t.Errorf("preview while loading = %q, want a placeholder", m.viewport.View())
+	}
+	firstMsg := first().(previewLoadedMsg)
+
+	// Move to the other branch before the first render is delivered.
+	m, cmd := press(t, m, tea.Key{Code: tea.KeyUp})
+	if cmd == nil {
+		t.Fatal("moving the cursor did not request a preview")
+	}
+	updated, _ := m.Update(firstMsg)
+	m = updated.(model)
+	if _, cached := m.previewCache[firstMsg.cacheKey]; cached {
+		t.Error("a cancelled preview was cached")
+	}
+	if strings.Contains(m.viewport.View(), firstMsg.cacheKey) {
+		t.Errorf("stale preview of %s shown after the cursor moved", firstMsg.cacheKey)
+	}
+
+	updated, _ = m.Update(cmd().(previewLoadedMsg))
+	m = updated.(model)
+	if selected := m.filtered[m.cursor].Text; !strings.Contains(m.viewport.View(), selected) {
+		t.Errorf("preview = %q, want the selected branch %s", m.viewport.View(), selected)
+	}
+}
+
+func TestUpdate_FileBatchesFillTheList(t *testing.T) {
+	m := model{mode: ModeFiles, viewport: viewport.New(), previewCache: map[string]string{}, mainHeight: 10, loading: true, filesGen: 2}
+
+	// A batch of a cancelled collection is ignored.
+	updated, _ := m.Update(filesBatchMsg{gen: 1, entries: []files.Entry{{Path: "old.go"}}})
+	m = updated.(model)
+	if len(m.fileEntries) != 0 {
+		t.Fatalf("stale batch added %d entries", len(m.fileEntries))
+	}
+
+	next := make(chan []files.Entry)
+	updated, cmd := m.Update(filesBatchMsg{gen: 2, entries: []files.Entry{{Path: "a.go"}, {Path: "b.go"}}, next: next})
+	m = updated.(model)
+	if len(m.filtered) != 2 || m.loading {
+		t.Fatalf("after the first batch: %d items, loading = %v; want 2 items shown", len(m.filtered), m.loading)
+	}
+	if cmd == nil || m.filesDone {
+		t.Fatal("the collection ended before its last batch")
+	}
+
+	updated, cmd = m.Update(filesBatchMsg{gen: 2, entries: []files.Entry{{Path: "c.go"}}, done: true})
+	m = updated.(model)
+	if len(m.filtered) != 3 || !m.filesDone {
+		t.Errorf("after the last batch: %d items, done = %v; want 3 items, done", len(m.filtered), m.filesDone)
+	}
+	if cmd != nil {
+		t.Error("kept waiting for batches after the collection ended")
+	}
+}
+
+func TestUpdate_FileBatchesAreMatchedAsTheyArrive(t *testing.T) {
+	m := model{mode: ModeFiles, input: textinput.New(), viewport: viewport.New(), previewCache: map[string]string{}, mainHeight: 10, loading: true}
+	m.input.SetValue("main")
+
+	updated, _ := m.Update(filesBatchMsg{entries: []files.Entry{{Path: "main.go"}, {Path: "README.md"}}, next: make(chan []files.Entry)})
+	m = updated.(model)
+	if len(m.filtered) != 1 || m.filtered[0].Text != "main.go" {
+		t.Fatalf("after the first batch, listed %v; want main.go", m.filtered)
+	}
+
+	// Move off the best match; later batches must not move the cursor.
+	updated, _ = m.Update(filesBatchMsg{entries: []files.Entry{{Path: "cmd/main.go"}, {Path: "go.mod"}}, next: make(chan []files.Entry)})
+	m = updated.(model)
+	m.cursor = slices.IndexFunc(m.filtered, func(item Item) bool { return item.Text == "cmd/main.go" })
+	updated, _ = m.Update(filesBatchMsg{entries: []files.Entry{{Path: "internal/main_test.go"}}, done: true})
+	m = updated.(model)
+
+	var listed []string
+	for _, item := range m.filtered {
+		listed = append(listed, item.Text)
+	}
+	m.filterItems("main")
+	var want []string
+	for _, item := range m.filtered {
+		want = append(want, item.Text)
+	}
+	if !slices.Equal(listed, want) {
+		t.Errorf("listed %q as the batches arrived, want %q as ranked at once", listed, want)
+	}
+	if got := listed[m.cursor]; got != "cmd/main.go" {
+		t.Errorf("cursor on %q after more batches, want cmd/main.go", got)
+	}
+	if len(m.allItems) != 5 {
+		t.Errorf("%d items in all, want the 5 files", len(m.allItems))
+	}
+}
+
+func TestUpdate_GrepBatchesListHits(t *testing.T) {
+	m := model{mode: ModeGrep, input: textinput.New(), viewport: viewport.New(), previewCache: map[string]string{}, mainHeight: 10, loading: true, grepGen: 3}
+
+	// Hits of a superseded query are ignored.
+	updated, _ := m.Update(grepBatchMsg{gen: 2, matches: []files.Match{{Path: "old.go", Line: 1, Text: "old"}}})
+	m = updated.(model)
+	if len(m.grepMatches) != 0 {
+		t.Fatalf("stale batch added %d matches", len(m.grepMatches))
+	}
+
+	hit := files.Match{Path: "main.go", Line: 12, Col: 7, Len: 6, Text: "\t\tx := needle()"}
+	updated, _ = m.Update(grepBatchMsg{gen: 3, matches: []files.Match{hit}, done: true})
+	m = updated.(model)
+	if len(m.filtered) != 1 || m.loading {
+		t.Fatalf("after the batch: %d items, loading = %v; want 1 item shown", len(m.filtered), m.loading)
+	}
+	item := m.filtered[0]
+	if want := "main.go:12: x := needle()"; item.Text != want {
+		t.Errorf("item text = %q, want %q", item.Text, want)
+	}
+	var matched strings.Builder
+	for _, i := range item.MatchedIndexes {
+		matched.WriteByte(item.Text[i])
+	}
+	if matched.String() != "needle" {
+		t.Errorf("highlighted %q, want the match %q", matched.String(), "needle")
+	}
+
+	m, _ = press(t, m, tea.Key{Code: tea.KeyEnter})
+	if len(m.choices) != 1 || m.choices[0] != "main.go" || !slices.Equal(m.choiceLines, []int{12}) {
+		t.Errorf("enter: choices = %q at lines %v, want main.go at 12", m.choices, m.choiceLines)
+	}
+}
+
+func TestUpdate_AltEnterOpensFile(t *testing.T) {
+	m := model{mode: ModeFiles, viewport: viewport.New(), previewCache: map[string]string{}, mainHeight: 10, fileEntries: []files.Entry{{Path: "main.go"}}}
+	m.loadItemsForMode()
+	m.filtered = append([]Item(nil), m.allItems...)
+
+	m, cmd := press(t, m, tea.Key{Code: tea.KeyEnter, Mod: tea.ModAlt})
+	if len(m.choices) != 1 || m.choices[0] != "main.go" || m.choiceAction != "open" {
+		t.Fatalf("alt+enter: choices = %q action = %q, want main.go open", m.choices, m.choiceAction)
+	}
+	if cmd == nil {
+		t.Error("alt+enter did not quit")
+	}
+}
+
+func TestUpdate_MarkedItemsAreAllChosen(t *testing.T) {
+	m := stashModel()
+	m.stashes = append(m.stashes, git.Stash{Ref: "stash@{2}", Hash: "c", Message: "oldest", Branch: "main"})
+	m.loadItemsForMode()
+	m.filtered = append([]Item(nil), m.allItems...)
+	m.cursor = len(m.filtered) - 1
+
+	// Mark stash@{0}, skip stash@{1}, mark stash@{2}.
+	m, _ = press(t, m, tea.Key{Code: tea.KeyTab})
+	m, _ = press(t, m, tea.Key{Code: tea.KeyUp})
+	m, _ = press(t, m, tea.Key{Code: tea.KeyTab})
+	if len(m.marked) != 2 {
+		t.Fatalf("marked %d items, want 2", len(m.marked))
+	}
+	if !m.isMarked(m.filtered[0]) || m.isMarked(m.filtered[1]) {
+		t.Errorf("marks = %+v, want stash@{2} and stash@{0} only", m.marked)
+	}
+
+	m, _ = press(t, m, tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	if m.confirm == nil || !strings.Contains(m.confirm.prompt, "2 stashes") {
+		t.Fatalf("confirm = %+v, want a prompt for 2 stashes", m.confirm)
+	}
+	m, _ = press(t, m, tea.Key{Code: 'y', Text: "y"})
+	// Dropping renumbers later stashes, so the oldest goes first.
+	if got := strings.Join(m.choices, " "); got != "stash@{2} stash@{0}" || m.choiceAction != "drop" {
+		t.Errorf("choices = %q action = %q, want stash@{2} stash@{0} drop", got, m.choiceAction)
+	}
+}
+
+func TestUpdate_BulkBranchDeleteRefusesCurrentBranch(t *testing.T) {
+	m := branchModel()
+	for range m.filtered {
+		m, _ = press(t, m, tea.Key{Code: tea.KeyTab})
+	}
+	m, cmd := press(t, m, tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	if cmd != nil || m.confirm != nil {
+		t.Error("ctrl+x with the current branch marked started a deletion")
+	}
+	if !strings.Contains(m.statusMsg, "main") {
+		t.Errorf("statusMsg = %q, want it to name the current branch", m.statusMsg)
+	}
+}
+
+func TestUpdate_StdinBatchesFillTheList(t *testing.T) {
+	m := model{mode: ModeStdin, input: textinput.New(), viewport: viewport.New(), previewCache: map[string]string{}, mainHeight: 10, loading: true}
+
+	updated, _ := m.Update(stdinBatchMsg{lines: []string{"alpha", "beta"}})
+	m = updated.(model)
+	if len(m.filtered) != 2 || m.loading || m.stdinDone {
+		t.Fatalf("after the first batch: %d items, loading = %v, done = %v; want 2 items, still reading", len(m.filtered), m.loading, m.stdinDone)
+	}
+	// The first line sits at the bottom, under the cursor.
+	if got := m.filtered[m.cursor].Text; got != "alpha" {
+		t.Errorf("selected %q, want the first line", got)
+	}
+
+	updated, _ = m.Update(stdinBatchMsg{lines: []string{"gamma"}, done: true})
+	m = updated.(model)
+	if len(m.filtered) != 3 || !m.stdinDone {
+		t.Fatalf("after the last batch: %d items, done = %v; want 3 items, done", len(m.filtered), m.stdinDone)
+	}
+
+	// Other modes cannot be switched to.
+	m, _ = press(t, m, tea.Key{Code: 'r', Mod: tea.ModCtrl})
+	if m.mode != ModeStdin {
+		t.Fatalf("ctrl+r switched to mode %v", m.mode)
+	}
+
+	m, _ = press(t, m, tea.Key{Code: tea.KeyEnter})
+	if len(m.choices) != 1 || m.choices[0] != "alpha" || m.exitStatus() != 0 {
+		t.Errorf("enter: choices = %q, exit status %d; want alpha, 0", m.choices, m.exitStatus())
+	}
+	if (model{mode: ModeStdin}).exitStatus() != ExitCancelledStatus {
+		t.Errorf("a cancelled stdin session does not exit with %d", ExitCancelledStatus)
+	}
+	if status := (model{mode: ModeStdin, copied: "alpha"}).exitStatus(); status != 0 {
+		t.Errorf("a stdin session that copied a line exits with %d, want 0", status)
+	}
+}
+
+func TestUpdate_StdinReadErrorIsShown(t *testing.T) {
+	m := model{mode: ModeStdin, input: textinput.New(), viewport: viewport.New(), previewCache: map[string]string{}, mainHeight: 10, loading: true}
+	updated, _ := m.Update(stdinBatchMsg{lines: []string{"alpha"}})
+	m = updated.(model)
+	updated, _ = m.Update(stdinBatchMsg{done: true, err: bufio.ErrTooLong})
+	m = updated.(model)
+	if !m.stdinDone || !strings.Contains(m.statusMsg, bufio.ErrTooLong.Error()) {
+		t.Errorf("done = %v, statusMsg = %q; want the read error shown", m.stdinDone, m.statusMsg)
+	}
+}
+
+func historyModel(entries []history.Entry) model {
+	m := model{
+		mode:           ModeHistory,
+		input:          textinput.New(),
+		viewport:       viewport.New(),
+		previewCache:   map[string]string{},
+		mainHeight:     10,
+		historyEntries: entries,
+		cwd:            "/src/app/web",
+		gitRoot:        "/src/app",
+	}
+	m.loadItemsForMode()
+	m.updateFilter("")
+	return m
+}
+
+func TestUpdate_HistoryScopeCycles(t *testing.T) {
+	// Newest first, as parsed
+	m := historyModel([]history.Entry{
+		{Cmd: "npm test", Paths: []string{"/src/app/web/package.json"}},
+		{Cmd: "go test ./...", Paths: []string{"/src/app/api"}},
+		{Cmd: "ls /tmp", Paths: []string{"/tmp"}},
+		{Cmd: "echo hi"},
+	})
+	listed := func() []string {
+		var cmds []string
+		for _, item := range m.filtered {
+			cmds = append(cmds, item.Text)
+		}
+		return cmds
+	}
+
+	steps := []struct {
+		scope historyScope
+		want  []string // Oldest first, as listed
+		label string
+	}{
+		{scopeDir, []string{"npm test"}, "in /src/app/web"},
+		{scopeRepo, []string{"go test ./...", "npm test"}, "in repository /src/app"},
+		{scopeAll, []string{"echo hi", "ls /tmp", "go test ./...", "npm test"}, ""},
+	}
+	for _, step := range steps {
+		m, _ = press(t, m, tea.Key{Code: 'r', Mod: tea.ModCtrl})
+		if m.historyScope != step.scope || !slices.Equal(listed(), step.want) {
+			t.Errorf("scope = %v listing %q, want %v listing %q", m.historyScope, listed(), step.scope, step.want)
+		}
+		// The preview shows the surrounding commands of the whole history.
+		if item := m.filtered[m.cursor]; m.historyEntries[item.Index].Cmd != item.Text {
+			t.Errorf("item %q has the index of %q", item.Text, m.historyEntries[item.Index].Cmd)
+		}
+		m.width = 200
+		m.ready = true
+		view := m.View().Content
+		if step.label != "" && !strings.Contains(view, step.label) {
+			t.Errorf("scope %v is not shown as %q", step.scope, step.label)
+		}
+	}
+
+	// Outside of a git repository the repository scope is skipped.
+	m.gitRoot = ""
+	m, _ = press(t, m, tea.Key{Code: 'r', Mod: tea.ModCtrl})
+	m, _ = press(t, m, tea.Key{Code: 'r', Mod: tea.ModCtrl})
+	if m.historyScope != scopeAll {
+		t.Errorf("scope = %v after two presses outside a repository, want all", m.historyScope)
+	}
+}
+
+func TestFilterItems_RanksCommandsRunHereHigher(t *testing.T) {
+	// The same command and age; only the directory differs.
+	m := historyModel([]history.Entry{
+		{Cmd: "make build", When: 1000, Count: 1, Paths: []string{"/elsewhere"}},
+		{Cmd: "make bench", When: 1000, Count: 1, Paths: []string{"/src/app/web"}},
+	})
+	m.filterItems("make")
+	if best := m.filtered[len(m.filtered)-1]; best.Text != "make bench" {
+		t.Errorf("best match = %q, want the command run in the current directory", best.Text)
+	}
+}
+
+func TestUpdate_HistoryDeleteAsksAndRemovesAtOnce(t *testing.T) {
+	m := historyModel([]history.Entry{
+		{Cmd: "export TOKEN=secret"},
+		{Cmd: "git push"},
+		{Cmd: "gti status"},
+	})
+	m.cursor = 0 // gti status, the oldest
+	m, _ = press(t, m, tea.Key{Code: tea.KeyTab})
+	m, _ = press(t, m, tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	if m.confirm == nil || !strings.Contains(m.confirm.prompt, `"gti status"`) {
+		t.Fatalf("ctrl+x did not ask to delete the selected command: %+v", m.confirm)
+	}
+	m, _ = press(t, m, tea.Key{Code: 'n', Text: "n"})
+	if len(m.historyEntries) != 3 {
+		t.Fatalf("a cancelled delete removed entries: %v", m.historyEntries)
+	}
+
+	// Marked commands are deleted together.
+	m.cursor = 2
+	m, _ = press(t, m, tea.Key{Code: tea.KeyTab})
+	m, _ = press(t, m, tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	if m.confirm == nil || !strings.Contains(m.confirm.prompt, "2 commands") {
+		t.Fatalf("ctrl+x with two marked did not ask to delete both: %+v", m.confirm)
+	}
+	m, cmd := press(t, m, tea.Key{Code: 'y', Text: "y"})
+	if cmd == nil {
+		t.Error("confirming did not start the deletion")
+	}
+	if len(m.filtered) != 1 || m.filtered[0].Text != "git push" || len(m.historyEntries) != 1 || len(m.marked) != 0 {
+		t.Errorf("after confirming, listed %v of %v with %d marked; want only git push", m.filtered, m.historyEntries, len(m.marked))
+	}
+
+	updated, _ := m.Update(historyDeletedMsg{err: os.ErrPermission})
+	if m = updated.(model); !strings.Contains(m.statusMsg, "permission denied") {
+		t.Errorf("statusMsg = %q, want the error", m.statusMsg)
+	}
+}
+
+func TestSelectItem_RevealsMaskedHistory(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Join(dataHome, "fish"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	input := "- cmd: mysql -u root -phunter2\n  when: 1000\n- cmd: mysql -u root -phunter3\n  when: 1100\n- cmd: ls\n  when: 1200\n"
+	if err := os.WriteFile(filepath.Join(dataHome, "fish", "fish_history"), []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := historyModel(newHistoryParser().Parse())
+	// ls is listed last, as the newest
+	if len(m.filtered) != 2 || m.filtered[0].Text != "mysql -u root -p****" {
+		t.Fatalf("listed %v, want the masked login first", m.filtered)
+	}
+	var buf strings.Builder
+	m.listWidth = 60
+	m.renderItem(&buf, 0, m.filtered[0])
+	if !strings.Contains(buf.String(), "🔑") {
+		t.Errorf("renderItem() = %q, want the secrets marker", buf.String())
+	}
+
+	// Both logins are listed as one, so both are given, newest first.
+	m.cursor = 0
+	if got := m.copyText(m.filtered[0]); got != "mysql -u root -phunter3\nmysql -u root -phunter2" {
+		t.Errorf("copyText() = %q, want the commands as typed", got)
+	}
+	m, cmd := press(t, m, tea.Key{Code: 'x', Mod: tea.ModCtrl})
+	if cmd == nil {
+		t.Fatal("ctrl+x on a masked command did not count what it stands for")
+	}
+	updated, _ := m.Update(cmd())
+	if m = updated.(model); m.confirm == nil || !strings.Contains(m.confirm.prompt, `2 commands masked as "mysql -u root -p****"`) {
+		t.Fatalf("ctrl+x did not name both logins: %+v", m.confirm)
+	}
+	m, _ = press(t, m, tea.Key{Code: 'n', Text: "n"})
+
+	m.selectItem()
+	if want := []string{"mysql -u root -phunter3", "mysql -u root -phunter2"}; !slices.Equal(m.choices, want) {
+		t.Errorf("choices = %q, want %q", m.choices, want)
+	}
+}
+
+func TestUpdate_StageTogglesMarkedFilesTogether(t *testing.T) {
+	dir := t.TempDir()
+	if err := exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Skipf("git init failed: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	// No previews, so alt+s only returns the git command.
+	m := model{mode: ModeStatus, input: textinput.New(), viewport: viewport.New(), previewCache: map[string]string{}, mainHeight: 10, previewHidden: true}
+	// toggle marks a.txt and c.txt, presses alt+s and loads the new status.
+	toggle := func() {
+		t.Helper()
+		status, err := git.NewRepository(".").Status()
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated, _ := m.Update(statusLoadedMsg{status: status})
+		m = updated.(model)
+		for i, item := range m.filtered {
+			if item.Text != "b.txt" {
+				m.cursor = i
+				m.toggleMark(0)
+			}
+		}
+		var cmd tea.Cmd
+		m, cmd = press(t, m, tea.Key{Code: 's', Mod: tea.ModAlt})
+		if cmd == nil {
+			t.Fatal("alt+s did not run git")
+		}
+		if msg := cmd().(gitOpDoneMsg); msg.err != nil {
+			t.Fatal(msg.err)
+		}
+	}
+	codes := func() string {
+		t.Helper()
+		status, err := git.NewRepository(".").Status()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var codes []string
+		for _, f := range status.Files {
+			codes = append(codes, f.Path+"="+f.Code())
+		}
+		return strings.Join(codes, " ")
+	}
+
+	toggle()
+	if got, want := codes(), "a.txt=A  c.txt=A  b.txt=??"; got != want {
+		t.Errorf("after staging, status = %q, want %q", got, want)
+	}
+	if len(m.marked) != 0 {
+		t.Errorf("%d items still marked after staging", len(m.marked))
+	}
+	toggle()
+	if got, want := codes(), "a.txt=?? b.txt=?? c.txt=??"; got != want {
+		t.Errorf("after unstaging, status = %q, want %q", got, want)
+	}
+}
@@ -14,51 +14,130 @@ import (
 	"github.com/jedipunkz/fuzz.fish/internal/ui"
 )
 
-// Pre-computed styles to avoid per-render allocation (lipgloss.NewStyle is expensive)
+// Pre-computed styles to avoid per-render allocation (lipgloss.NewStyle is
+// expensive), built from ui.Colors by buildStyles
 var (
+	boxStyle     lipgloss.Style
+	warningStyle lipgloss.Style
+	scopeStyle   lipgloss.Style // The directory history is scoped to
+
+	// Search box styles
+	promptStyle    lipgloss.Style
+	inputTextStyle lipgloss.Style
+	spinnerStyle   lipgloss.Style
+
+	// Item list styles
+	itemSelectedStyle   lipgloss.Style
+	itemNormalStyle     lipgloss.Style
+	cursorSelectedStyle lipgloss.Style
+	markerStyle         lipgloss.Style
+
+	// Match highlight styles
+	matchSelectedStyle lipgloss.Style
+	matchNormalStyle   lipgloss.Style
+
+	// Padding styles
+	paddingSelectedStyle lipgloss.Style
+	paddingNormalStyle   lipgloss.Style
+
+	// Time ago styles
+	timeAgoSelectedStyle lipgloss.Style
+	timeAgoNormalStyle   lipgloss.Style
+)
+
+func init() {
+	buildStyles()
+}
+
+// buildStyles builds the styles from the palette in use, again after it or
+// ui.Monochrome has been changed.
+func buildStyles() {
+	if ui.Monochrome {
+		// Without colors the selection stands out in reverse video and
+		// matches in bold.
+		boxStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder())
+		warningStyle = lipgloss.NewStyle().Bold(true)
+		scopeStyle = lipgloss.NewStyle().Bold(true)
+		promptStyle = lipgloss.NewStyle().Bold(true)
+		inputTextStyle = lipgloss.NewStyle()
+		spinnerStyle = lipgloss.NewStyle()
+		itemSelectedStyle = lipgloss.NewStyle().Reverse(true)
+		itemNormalStyle = lipgloss.NewStyle()
+		cursorSelectedStyle = lipgloss.NewStyle().Reverse(true)
+		markerStyle = lipgloss.NewStyle().Bold(true)
+		matchSelectedStyle = lipgloss.NewStyle().Reverse(true).Bold(true)
+		matchNormalStyle = lipgloss.NewStyle().Bold(true)
+		paddingSelectedStyle = lipgloss.NewStyle().Reverse(true)
+		paddingNormalStyle = lipgloss.NewStyle()
+		timeAgoSelectedStyle = lipgloss.NewStyle().Reverse(true)
+		timeAgoNormalStyle = lipgloss.NewStyle()
+		return
+	}
+
 	boxStyle = lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color(ui.ColorBorder))
+		BorderForeground(lipgloss.Color(ui.Colors.Border))
 
-	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("220"))
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(ui.Colors.Yellow))
+	scopeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(ui.Colors.Purple))
+
+	promptStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(ui.Colors.Cyan))
+	inputTextStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(ui.Colors.Foreground))
+	spinnerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(ui.Colors.Cyan))
 
-	// Item list styles
 	itemSelectedStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ui.ColorCyan)).
-				Background(lipgloss.Color(ui.ColorSelectionBg)).
-				Bold(true)
+		Foreground(lipgloss.Color(ui.Colors.Cyan)).
+		Background(lipgloss.Color(ui.Colors.SelectionBg)).
+		Bold(true)
 
 	itemNormalStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ui.ColorForeground))
+		Foreground(lipgloss.Color(ui.Colors.Foreground))
 
 	cursorSelectedStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ui.ColorPurple)).
-				Background(lipgloss.Color(ui.ColorSelectionBg))
+		Foreground(lipgloss.Color(ui.Colors.Purple)).
+		Background(lipgloss.Color(ui.Colors.SelectionBg))
+
+	markerStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(ui.Colors.Purple)).
+		Bold(true)
 
-	// Match highlight styles
 	matchSelectedStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ui.ColorPink)).
-				Background(lipgloss.Color(ui.ColorSelectionBg)).
-				Bold(true)
+		Foreground(lipgloss.Color(ui.Colors.Pink)).
+		Background(lipgloss.Color(ui.Colors.SelectionBg)).
+		Bold(true)
 
 	matchNormalStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ui.ColorPink)).
-				Bold(true)
+		Foreground(lipgloss.Color(ui.Colors.Pink)).
+		Bold(true)
 
-	// Padding styles
 	paddingSelectedStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color(ui.ColorSelectionBg))
+		Background(lipgloss.Color(ui.Colors.SelectionBg))
 
 	paddingNormalStyle = lipgloss.NewStyle()
 
-	// Time ago styles
 	timeAgoSelectedStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ui.ColorTimeAgo)).
-				Background(lipgloss.Color(ui.ColorSelectionBg))
+		Foreground(lipgloss.Color(ui.Colors.TimeAgo)).
+		Background(lipgloss.Color(ui.Colors.SelectionBg))
 
 	timeAgoNormalStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ui.ColorTimeAgo))
-)
+		Foreground(lipgloss.Color(ui.Colors.TimeAgo))
+}
+
+// previewView renders the preview pane, or the help in its place.
+func (m model) previewView() string {
+	if m.showHelp {
+		return m.helpPane(m.viewport.Width(), m.viewport.Height())
+	}
+	return m.viewport.View()
+}
+
+// helpPane renders the help to fit a pane of width × height.
+func (m model) helpPane(width, height int) string {
+	return lipgloss.NewStyle().
+		Width(width).
+		MaxHeight(height).
+		Render(m.helpView())
+}
 
 // View renders the application view
 func (m model) View() tea.View {
@@ -75,24 +154,11 @@ func (m model) View() tea.View {
 
 	// Show loading indicator when async loading with no items yet
 	if m.loading && len(m.filtered) == 0 {
-		if m.mainHeight > 1 {
+		if m.mainHeight > 1 && !m.reverse {
 			listBuilder.WriteString(strings.Repeat("\n", m.mainHeight-1))
 		}
 		listBuilder.WriteString("Loading...")
-
-		listView := listBuilder.String()
-		previewView := m.viewport.View()
-
-		// In lipgloss v2, Width/Height include borders, so add 2 for left+right / top+bottom borders
-		listBox := boxStyle.Width(m.listWidth + 2).Height(m.mainHeight + 2).Render(listView)
-		previewBox := boxStyle.Width(m.viewport.Width() + 2).Height(m.mainHeight + 2).Render(previewView)
-		inputBox := boxStyle.Width(m.width).Padding(0, 1).Render(inputView)
-
-		mainView := lipgloss.JoinHorizontal(lipgloss.Top, listBox, previewBox)
-		v := tea.NewView(lipgloss.JoinVertical(lipgloss.Left, mainView, inputBox))
-		v.AltScreen = true
-		v.Cursor = m.inputCursor()
-		return v
+		return m.frame(listBuilder.String(), inputView)
 	}
 
 	// Determine visible range
@@ -102,43 +168,84 @@ func (m model) View() tea.View {
 		end = len(m.filtered)
 	}
 
-	// If items < height, offset is 0, we need to push items to bottom.
-	// With no items the padding newlines alone would render mainHeight+1 lines,
-	// so leave the pane empty and let the box height fill it.
-	visibleCount := end - start
-	padding := m.mainHeight - visibleCount
-	if visibleCount > 0 && padding > 0 {
-		listBuilder.WriteString(strings.Repeat("\n", padding))
-	}
+	if m.reverse {
+		// The best match, last in filtered, is drawn at the top; the box
+		// height fills the rest of the pane.
+		for i := end - 1; i >= start; i-- {
+			m.renderItem(&listBuilder, i, m.filtered[i])
+			if i > start {
+				listBuilder.WriteString("\n")
+			}
+		}
+	} else {
+		// If items < height, offset is 0, we need to push items to bottom.
+		// With no items the padding newlines alone would render mainHeight+1
+		// lines, so leave the pane empty and let the box height fill it.
+		visibleCount := end - start
+		padding := m.mainHeight - visibleCount
+		if visibleCount > 0 && padding > 0 {
+			listBuilder.WriteString(strings.Repeat("\n", padding))
+		}
 
-	for i := start; i < end; i++ {
-		item := m.filtered[i]
-		m.renderItem(&listBuilder, i, item)
-		if i < end-1 {
-			listBuilder.WriteString("\n")
+		for i := start; i < end; i++ {
+			item := m.filtered[i]
+			m.renderItem(&listBuilder, i, item)
+			if i < end-1 {
+				listBuilder.WriteString("\n")
+			}
 		}
 	}
 
-	listView := listBuilder.String()
-	previewView := m.viewport.View()
+	// Build input line with optional status message
+	inputContent := inputView
+	if m.confirm != nil {
+		inputContent = inputView + "  " + warningStyle.Render(m.confirm.prompt)
+	} else if m.statusMsg != "" {
+		inputContent = inputView + "  " + warningStyle.Render(m.statusMsg)
+	} else if m.mode == ModeFiles && m.filesCancel != nil {
+		// Files are still being collected.
+		inputContent = inputView + "  " + m.spinner.View() + " " + fmt.Sprintf("%d files", len(m.fileEntries))
+	} else if m.mode == ModeGrep && m.grepCancel != nil {
+		// Files are still being searched.
+		inputContent = inputView + "  " + m.spinner.View() + " " + fmt.Sprintf("%d matches", len(m.grepMatches))
+	} else if m.mode == ModeStdin && !m.stdinDone {
+		// Input is still arriving.
+		inputContent = inputView + "  " + m.spinner.View() + " " + fmt.Sprintf("%d lines", len(m.stdinLines))
+	} else if m.mode == ModeHistory && m.historyScope == scopeDir {
+		inputContent = inputView + "  " + scopeStyle.Render("in "+ui.FormatDir(m.cwd))
+	} else if m.mode == ModeHistory && m.historyScope == scopeRepo {
+		inputContent = inputView + "  " + scopeStyle.Render("in repository "+ui.FormatDir(m.gitRoot))
+	}
+
+	return m.frame(listBuilder.String(), inputContent)
+}
+
+// frame lays the list, the preview and the input line out in their boxes as
+// m.layout sized them.
+func (m model) frame(listView, inputContent string) tea.View {
+	// With the preview hidden the help takes the place of the list.
+	if m.previewHidden && m.showHelp {
+		listView = m.helpPane(m.listWidth, m.mainHeight)
+	}
 
 	// List pane with border
 	// In lipgloss v2, Width/Height include borders, so add 2 for left+right / top+bottom borders
-	listBox := boxStyle.
+	mainView := boxStyle.
 		Width(m.listWidth + 2).
 		Height(m.mainHeight + 2).
 		Render(listView)
 
-	// Preview pane with border
-	previewBox := boxStyle.
-		Width(m.viewport.Width() + 2).
-		Height(m.mainHeight + 2).
-		Render(previewView)
-
-	// Build input line with optional status message
-	inputContent := inputView
-	if m.statusMsg != "" {
-		inputContent = inputView + "  " + warningStyle.Render(m.statusMsg)
+	if !m.previewHidden {
+		// Preview pane with border
+		previewBox := boxStyle.
+			Width(m.viewport.Width() + 2).
+			Height(m.viewport.Height() + 2).
+			Render(m.previewView())
+		if m.previewBelow {
+			mainView = lipgloss.JoinVertical(lipgloss.Left, mainView, previewBox)
+		} else {
+			mainView = lipgloss.JoinHorizontal(lipgloss.Top, mainView, previewBox)
+		}
 	}
 
 	// Input box with border
@@ -147,15 +254,12 @@ func (m model) View() tea.View {
 		Padding(0, 1).
 		Render(inputContent)
 
-	mainView := lipgloss.JoinHorizontal(lipgloss.Top,
-		listBox,
-		previewBox,
-	)
-
-	v := tea.NewView(lipgloss.JoinVertical(lipgloss.Left,
-		mainView,
-		inputBox,
-	))
+	var v tea.View
+	if m.reverse {
+		v = tea.NewView(lipgloss.JoinVertical(lipgloss.Left, inputBox, mainView))
+	} else {
+		v = tea.NewView(lipgloss.JoinVertical(lipgloss.Left, mainView, inputBox))
+	}
 	v.AltScreen = true
 	v.Cursor = m.inputCursor()
 	return v
@@ -169,8 +273,16 @@ func (m model) inputCursor() *tea.Cursor {
 	}
 	// X: left border (1) + left padding (1)
 	c.X += 2
-	// Y: main panes height (mainHeight + 2 borders) + input box top border (1)
-	c.Y += m.mainHeight + 2 + 1
+	// Y: input box top border (1), below the panes unless the list is
+	// reversed
+	c.Y++
+	if !m.reverse {
+		// List pane height (mainHeight + 2 borders), and the preview's below it
+		c.Y += m.mainHeight + 2
+		if m.previewBelow && !m.previewHidden {
+			c.Y += m.viewport.Height() + 2
+		}
+	}
 	return c
 }
 
@@ -208,6 +320,11 @@ func (m model) renderItem(w io.Writer, index int, i Item) {
 		if entry, ok := i.Original.(history.Entry); ok && entry.When > 0 {
 			timeAgo = formatTimeAgo(entry.When)
 		}
+		// Commands with masked secrets are marked, so they are not taken
+		// for what was typed.
+		if hasSecrets(i) {
+			prefix = "🔑 "
+		}
 	case ModeGitBranch:
 		var icon string
 		if i.IsCurrent {
@@ -228,18 +345,49 @@ func (m model) renderItem(w io.Writer, index int, i Item) {
 		prefix = icon + " "
 	case ModeWorktree:
 		icon := " "
-		if i.IsCurrent {
+		wt, ok := i.Original.(git.Worktree)
+		switch {
+		case i.IsCurrent:
 			icon = "*"
+		case ok && wt.Locked:
+			icon = "L"
+		case ok && wt.Prunable:
+			icon = "!"
 		}
 		prefix = icon + " "
 		// Matches the SearchText built in loadItemsForMode, so match indexes
 		// cover the branch suffix too.
-		if wt, ok := i.Original.(git.Worktree); ok {
+		if ok {
 			text = text + " [" + wt.Branch + "]"
 		}
+	case ModeCommit:
+		// Matches the SearchText built in loadItemsForMode.
+		if c, ok := i.Original.(git.Commit); ok {
+			text = text + " (" + c.Author + ")"
+			timeAgo = formatTimeAgo(c.Timestamp)
+		}
+	case ModeStatus:
+		if f, ok := i.Original.(git.FileStatus); ok {
+			prefix = f.Code() + " "
+		}
+	case ModeStash:
+		// Matches the SearchText built in loadItemsForMode.
+		if st, ok := i.Original.(git.Stash); ok {
+			text = text + " [" + st.Branch + "]"
+			timeAgo = formatTimeAgo(st.Timestamp)
+		}
+	case ModeStdin:
+		// Tabs render wider than one cell; spaces keep the byte offsets.
+		text = strings.ReplaceAll(text, "\t", " ")
 	}
 
-	cursorStr := cursor + " "
+	// Items marked for multi-select get a marker next to the cursor.
+	marker := " "
+	isMarked := m.isMarked(i)
+	if isMarked {
+		marker = "+"
+	}
+	cursorStr := cursor + marker
 	cursorWidth := lipgloss.Width(cursorStr)
 
 	// Reserve space for time ago display
@@ -260,6 +408,8 @@ func (m model) renderItem(w io.Writer, index int, i Item) {
 	var renderedCursor string
 	if isSelected {
 		renderedCursor = cursorSelectedStyle.Render(cursorStr)
+	} else if isMarked {
+		renderedCursor = cursor + markerStyle.Render(marker)
 	} else {
 		renderedCursor = cursorStr
 	}
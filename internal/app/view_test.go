@@ -5,8 +5,12 @@ import (
 	"testing"
 	"unicode/utf8"
 
+	"charm.land/bubbles/v2/textinput"
 	"charm.land/bubbles/v2/viewport"
+	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/jedipunkz/fuzz.fish/internal/config"
 	"github.com/jedipunkz/fuzz.fish/internal/files"
 	"github.com/jedipunkz/fuzz.fish/internal/git"
 	"github.com/jedipunkz/fuzz.fish/internal/history"
@@ -86,6 +90,16 @@ func TestRenderItem_HighlightsMatchedText(t *testing.T) {
 			},
 			want: "ma",
 		},
+		{
+			name: "commit mode covers the author suffix",
+			mode: ModeCommit,
+			item: Item{
+				Text:           "7a2ca8c Fix parser",
+				Original:       git.Commit{ShortHash: "7a2ca8c", Subject: "Fix parser", Author: "Alice"},
+				MatchedIndexes: []int{20, 21, 22}, // "Ali" in the "(Alice)" suffix
+			},
+			want: "Ali",
+		},
 		{
 			name: "multibyte text uses byte offsets",
 			mode: ModeHistory,
@@ -168,3 +182,113 @@ func TestUpdate_LoadingStaysWithTheActiveMode(t *testing.T) {
 		t.Errorf("historyEntries = %d, want the loaded entry to be kept", len(got.historyEntries))
 	}
 }
+
+func TestLayout(t *testing.T) {
+	t.Cleanup(func() { settings = config.Default() })
+
+	tests := []struct {
+		name        string
+		preview     string
+		hidden      bool
+		width       int
+		wantList    [2]int // width, height
+		wantPreview [2]int
+		wantBelow   bool
+		wantCursorY int
+	}{
+		{"beside", config.PreviewRight, false, 100, [2]int{58, 25}, [2]int{38, 25}, false, 28},
+		{"below", config.PreviewBottom, false, 100, [2]int{98, 15}, [2]int{98, 8}, true, 28},
+		{"narrow terminal", config.PreviewRight, false, 79, [2]int{77, 15}, [2]int{77, 8}, true, 28},
+		{"hidden", config.PreviewRight, true, 100, [2]int{98, 25}, [2]int{0, 0}, false, 28},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings = config.Default()
+			settings.Layout.Preview = tt.preview
+			m := model{input: textinput.New(), viewport: viewport.New(), width: tt.width, height: 30, previewHidden: tt.hidden}
+			m.input.SetVirtualCursor(false)
+			m.input.Focus()
+			m.layout()
+
+			if got := [2]int{m.listWidth, m.mainHeight}; got != tt.wantList {
+				t.Errorf("list = %v, want %v", got, tt.wantList)
+			}
+			if got := [2]int{m.viewport.Width(), m.viewport.Height()}; got != tt.wantPreview {
+				t.Errorf("preview = %v, want %v", got, tt.wantPreview)
+			}
+			if m.previewBelow != tt.wantBelow {
+				t.Errorf("previewBelow = %v, want %v", m.previewBelow, tt.wantBelow)
+			}
+			// The panes and the input box fill the terminal, and the
+			// cursor is on the input line.
+			m.ready = true
+			lines := strings.Split(m.View().Content, "\n")
+			if len(lines) != 30 {
+				t.Errorf("view has %d lines, want 30", len(lines))
+			}
+			if c := m.inputCursor(); c == nil || c.Y != tt.wantCursorY {
+				t.Errorf("input cursor = %+v, want it on line %d", c, tt.wantCursorY)
+			}
+		})
+	}
+}
+
+func TestView_ReversedListStartsAtTheTop(t *testing.T) {
+	m := model{
+		mode:          ModeStdin,
+		input:         textinput.New(),
+		viewport:      viewport.New(),
+		previewCache:  map[string]string{},
+		width:         60,
+		height:        12,
+		ready:         true,
+		reverse:       true,
+		previewHidden: true,
+		filtered:      []Item{{Text: "worst"}, {Text: "middle"}, {Text: "best"}},
+	}
+	m.input.SetVirtualCursor(false)
+	m.input.Focus()
+	m.layout()
+	m.resetCursor()
+
+	lines := strings.Split(ansi.Strip(m.View().Content), "\n")
+	// Input box (3 lines), then the list's top border and items.
+	for i, want := range []string{"best", "middle", "worst"} {
+		if got := lines[4+i]; !strings.Contains(got, want) {
+			t.Errorf("line %d = %q, want %s", 4+i, got, want)
+		}
+	}
+	if c := m.inputCursor(); c == nil || c.Y != 1 {
+		t.Errorf("input cursor = %+v, want it on line 1", c)
+	}
+
+	// Down moves away from the best match at the top.
+	m, _ = press(t, m, tea.Key{Code: tea.KeyDown})
+	if got := m.filtered[m.cursor].Text; got != "middle" {
+		t.Errorf("after down, selected %q, want middle", got)
+	}
+	m, _ = press(t, m, tea.Key{Code: tea.KeyTab})
+	if got := m.filtered[m.cursor].Text; got != "best" || len(m.marked) != 1 {
+		t.Errorf("after tab, selected %q with %d marked, want best with 1", got, len(m.marked))
+	}
+}
+
+func TestUpdate_TogglePreview(t *testing.T) {
+	m := stashModel()
+	m.input = textinput.New()
+	m.width, m.height = 100, 30
+	m.layout()
+	m.updatePreview()
+	if m.viewport.Width() == 0 || m.lastPreviewKey == "" {
+		t.Fatal("the preview is not shown to begin with")
+	}
+
+	m, _ = press(t, m, tea.Key{Code: 'v', Mod: tea.ModAlt})
+	if !m.previewHidden || m.listWidth != 98 || m.lastPreviewKey != "" {
+		t.Errorf("after alt+v: hidden = %v, listWidth = %d, preview key = %q; want the list alone", m.previewHidden, m.listWidth, m.lastPreviewKey)
+	}
+	m, _ = press(t, m, tea.Key{Code: 'v', Mod: tea.ModAlt})
+	if m.previewHidden || m.listWidth != 58 || m.lastPreviewKey == "" {
+		t.Errorf("after alt+v again: hidden = %v, listWidth = %d, preview key = %q; want the preview back", m.previewHidden, m.listWidth, m.lastPreviewKey)
+	}
+}
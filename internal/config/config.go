@@ -0,0 +1,357 @@
+// Package config loads the user's settings from
+// $XDG_CONFIG_HOME/fuzz.fish/config.toml.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/jedipunkz/fuzz.fish/internal/files"
+	"github.com/jedipunkz/fuzz.fish/internal/git"
+	"github.com/jedipunkz/fuzz.fish/internal/history"
+	"github.com/jedipunkz/fuzz.fish/internal/scoring"
+	"github.com/jedipunkz/fuzz.fish/internal/ui"
+)
+
+// Config holds every setting that can be changed in the config file. Fields
+// the file leaves out keep their defaults.
+type Config struct {
+	Theme   string                 `toml:"theme"`  // A built-in theme (ui.Themes) or one of Themes
+	Themes  map[string]CustomTheme `toml:"themes"` // Themes defined in the file, by name
+	Colors  ui.Palette             `toml:"colors"` // Colors changed from those of the theme
+	Keys    Keys                   `toml:"keys"`
+	Layout  Layout                 `toml:"layout"`
+	Scoring scoring.Config         `toml:"scoring"`
+	Files   Files                  `toml:"files"`
+	Preview Preview                `toml:"preview"`
+	Secrets Secrets                `toml:"secrets"`
+	Git     Git                    `toml:"git"`
+}
+
+// CustomTheme is a theme defined in the config file. It starts from a
+// built-in theme and changes the colors and chroma style it sets.
+type CustomTheme struct {
+	ui.Theme
+	Base string `toml:"base"` // The built-in theme to start from; ui.DefaultTheme when empty
+}
+
+// Keys maps action names (e.g. "mode.files") to the keys that trigger them,
+// replacing their default keys. In the file, dotted names are written as
+// nested keys: `mode.files = "ctrl+e"` under [keys].
+type Keys map[string][]string
+
+// Layout holds the arrangement and proportions of the panes
+type Layout struct {
+	Preview       string `toml:"preview"`        // Where the preview is: PreviewRight, PreviewBottom or PreviewHidden
+	ListWidth     int    `toml:"list_width"`     // Width of the list in percent of the terminal; the preview gets the rest
+	PreviewHeight int    `toml:"preview_height"` // Height of a preview below the list in percent of the panes
+	StackBelow    int    `toml:"stack_below"`    // Terminal width under which the preview moves below the list; 0 never
+	Reverse       bool   `toml:"reverse"`        // List from the best match downwards, with the input at the top
+}
+
+// Places of the preview pane
+const (
+	PreviewRight  = "right"
+	PreviewBottom = "bottom"
+	PreviewHidden = "hidden" // Until it is toggled on
+)
+
+// Files holds the settings of files mode and content search
+type Files struct {
+	SkipDirs []string `toml:"skip_dirs"` // Directories never descended into, by name
+}
+
+// Preview holds the settings of the preview pane
+type Preview struct {
+	HistoryContextBefore int `toml:"history_context_before"` // Commands shown before the selected one
+	HistoryContextAfter  int `toml:"history_context_after"`  // Commands shown after the selected one
+}
+
+// Secrets holds the settings of the masking of secrets in history commands
+type Secrets struct {
+	Redact  bool           `toml:"redact"`  // Mask secrets; when false, commands are shown as they were typed
+	Disable []string       `toml:"disable"` // Built-in rules (history.DefaultRules) not to use, by name
+	Rules   []history.Rule `toml:"rules"`   // Rules used besides the built-in ones
+}
+
+// Git holds the settings of the git modes
+type Git struct {
+	// WorktreePath is where alt+w creates worktrees: {root} is the main
+	// worktree, {repo} its name and {branch} the branch name.
+	WorktreePath string `toml:"worktree_path"`
+}
+
+// Redactor returns the redactor the settings choose; nil when secrets are not
+// masked.
+func (s Secrets) Redactor() (*history.Redactor, error) {
+	if !s.Redact {
+		return nil, nil
+	}
+	rules := make([]history.Rule, 0, len(history.DefaultRules)+len(s.Rules))
+	for _, rule := range history.DefaultRules {
+		if !slices.Contains(s.Disable, rule.Name) {
+			rules = append(rules, rule)
+		}
+	}
+	return history.NewRedactor(append(rules, s.Rules...))
+}
+
+// Default returns the settings used without a config file.
+func Default() Config {
+	return Config{
+		Theme:   ui.DefaultTheme,
+		Keys:    Keys{},
+		Layout:  Layout{Preview: PreviewRight, ListWidth: 60, PreviewHeight: 40, StackBelow: 80},
+		Scoring: scoring.DefaultConfig(),
+		Files:   Files{SkipDirs: files.DefaultSkipDirs},
+		Preview: Preview{
+			HistoryContextBefore: ui.HistoryContextLinesBefore,
+			HistoryContextAfter:  ui.HistoryContextLinesAfter,
+		},
+		Secrets: Secrets{Redact: true},
+		Git:     Git{WorktreePath: git.DefaultWorktreePathTemplate},
+	}
+}
+
+// Path returns where the config file is read from: $FUZZ_FISH_CONFIG when
+// set, otherwise fuzz.fish/config.toml in $XDG_CONFIG_HOME (~/.config by
+// default).
+func Path() string {
+	if p := os.Getenv("FUZZ_FISH_CONFIG"); p != "" {
+		return p
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "fuzz.fish", "config.toml")
+}
+
+// Load reads the config file at path. A missing file is not an error; the
+// defaults are returned for it.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || path == "" {
+		return Default(), nil
+	}
+	if err != nil {
+		return Default(), err
+	}
+	cfg, err := Parse(string(data))
+	if err != nil {
+		return Default(), fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse parses the contents of a config file on top of the defaults. Keys
+// that are not settings and values out of range are errors, so typos do not
+// go unnoticed.
+func Parse(data string) (Config, error) {
+	cfg := Default()
+	md, err := toml.Decode(data, &cfg)
+	if err != nil {
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			return cfg, errors.New(perr.ErrorWithPosition())
+		}
+		return cfg, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = strconv.Quote(k.String())
+		}
+		return cfg, fmt.Errorf("unknown setting %s", strings.Join(keys, ", "))
+	}
+	return cfg, cfg.validate()
+}
+
+// colorPattern matches the color values lipgloss understands: hex codes and
+// ANSI color numbers.
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[0-9]{1,3})$`)
+
+func (c Config) validate() error {
+	if c.Layout.ListWidth < 10 || c.Layout.ListWidth > 90 {
+		return fmt.Errorf("layout.list_width: %d is not between 10 and 90", c.Layout.ListWidth)
+	}
+	if c.Layout.PreviewHeight < 10 || c.Layout.PreviewHeight > 90 {
+		return fmt.Errorf("layout.preview_height: %d is not between 10 and 90", c.Layout.PreviewHeight)
+	}
+	if c.Layout.StackBelow < 0 {
+		return errors.New("layout.stack_below must not be negative")
+	}
+	switch c.Layout.Preview {
+	case PreviewRight, PreviewBottom, PreviewHidden:
+	default:
+		return fmt.Errorf("layout.preview: %q is not %q, %q or %q", c.Layout.Preview, PreviewRight, PreviewBottom, PreviewHidden)
+	}
+	if c.Preview.HistoryContextBefore < 0 || c.Preview.HistoryContextAfter < 0 {
+		return errors.New("preview: history context lines must not be negative")
+	}
+	if c.Scoring.MaxGapChars < 0 {
+		return errors.New("scoring.max_gap_chars must not be negative")
+	}
+	if !strings.Contains(c.Git.WorktreePath, "{branch}") {
+		return fmt.Errorf("git.worktree_path: %q has no {branch}, so every worktree would get the same path", c.Git.WorktreePath)
+	}
+	if err := c.Secrets.validate(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(c.Themes))
+	for name := range c.Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := c.Themes[name]
+		if err := validatePalette("themes."+name, t.Palette); err != nil {
+			return err
+		}
+		if _, ok := ui.Themes[t.Base]; t.Base != "" && !ok {
+			return fmt.Errorf("themes.%s.base: unknown built-in theme %q (built-in: %s)", name, t.Base, strings.Join(ui.ThemeNames(), ", "))
+		}
+		if t.Chroma != "" && !ui.HasChromaStyle(t.Chroma) {
+			return fmt.Errorf("themes.%s.chroma: unknown chroma style %q", name, t.Chroma)
+		}
+	}
+	if err := validatePalette("colors", c.Colors); err != nil {
+		return err
+	}
+	_, err := c.ResolveTheme()
+	return err
+}
+
+func (s Secrets) validate() error {
+	for _, name := range s.Disable {
+		if !slices.ContainsFunc(history.DefaultRules, func(r history.Rule) bool { return r.Name == name }) {
+			return fmt.Errorf("secrets.disable: unknown built-in rule %q", name)
+		}
+	}
+	for i, rule := range s.Rules {
+		if rule.Name == "" || rule.Pattern == "" {
+			return fmt.Errorf("secrets.rules[%d]: a rule needs a name and a pattern", i)
+		}
+	}
+	if _, err := (Secrets{Redact: true, Rules: s.Rules}).Redactor(); err != nil {
+		return fmt.Errorf("secrets.rules: %w", err)
+	}
+	return nil
+}
+
+// validatePalette checks the colors set in p; section is where p is in the
+// file, for errors.
+func validatePalette(section string, p ui.Palette) error {
+	for _, f := range paletteFields(&p) {
+		v := *f.value
+		if v == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(v); !colorPattern.MatchString(v) || (err == nil && n > 255) {
+			return fmt.Errorf("%s.%s: %q is not a hex color (#rrggbb) or ANSI color number (0-255)", section, f.name, v)
+		}
+	}
+	return nil
+}
+
+// paletteField is a color of a Palette and its name in the file
+type paletteField struct {
+	name  string
+	value *string
+}
+
+func paletteFields(p *ui.Palette) []paletteField {
+	return []paletteField{
+		{"cyan", &p.Cyan}, {"purple", &p.Purple}, {"foreground", &p.Foreground},
+		{"yellow", &p.Yellow}, {"orange", &p.Orange}, {"comment", &p.Comment},
+		{"blue", &p.Blue}, {"pink", &p.Pink}, {"selection_bg", &p.SelectionBg},
+		{"border", &p.Border}, {"time_ago", &p.TimeAgo},
+	}
+}
+
+// overlay returns p with the colors set in changes replacing its own.
+func overlay(p, changes ui.Palette) ui.Palette {
+	pf, cf := paletteFields(&p), paletteFields(&changes)
+	for i := range pf {
+		if v := *cf[i].value; v != "" {
+			*pf[i].value = v
+		}
+	}
+	return p
+}
+
+// ResolveTheme returns the theme the settings choose, with Colors applied.
+func (c Config) ResolveTheme() (ui.Theme, error) {
+	var t ui.Theme
+	if custom, ok := c.Themes[c.Theme]; ok {
+		base := custom.Base
+		if base == "" {
+			base = ui.DefaultTheme
+		}
+		b, ok := ui.Themes[base]
+		if !ok {
+			return t, fmt.Errorf("themes.%s.base: unknown built-in theme %q", c.Theme, base)
+		}
+		t = b
+		t.Palette = overlay(b.Palette, custom.Palette)
+		if custom.Chroma != "" {
+			t.Chroma = custom.Chroma
+		}
+		t.ANSI = b.ANSI || custom.ANSI
+	} else if t, ok = ui.Themes[c.Theme]; !ok {
+		return t, fmt.Errorf("theme: unknown theme %q (built-in: %s)", c.Theme, strings.Join(ui.ThemeNames(), ", "))
+	}
+	t.Palette = overlay(t.Palette, c.Colors)
+	return t, nil
+}
+
+// UnmarshalTOML reads the [keys] table: nested tables make up dotted action
+// names, and each action takes a key or a list of keys.
+func (k *Keys) UnmarshalTOML(v any) error {
+	table, ok := v.(map[string]any)
+	if !ok {
+		return errors.New("keys: want a table of actions")
+	}
+	*k = Keys{}
+	return k.add("", table)
+}
+
+func (k Keys) add(prefix string, table map[string]any) error {
+	for name, v := range table {
+		action := prefix + name
+		switch v := v.(type) {
+		case map[string]any:
+			if err := k.add(action+".", v); err != nil {
+				return err
+			}
+		case string:
+			k[action] = []string{v}
+		case []any:
+			keys := make([]string, len(v))
+			for i, key := range v {
+				s, ok := key.(string)
+				if !ok {
+					return fmt.Errorf("keys.%s: want a key or a list of keys", action)
+				}
+				keys[i] = s
+			}
+			k[action] = keys
+		default:
+			return fmt.Errorf("keys.%s: want a key or a list of keys", action)
+		}
+	}
+	return nil
+}
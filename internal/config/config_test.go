@@ -0,0 +1,198 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse(`
+[keys]
+mode.files = "ctrl+e"
+select = ["enter", "ctrl+j"]
+
+[layout]
+list_width = 50
+preview = "bottom"
+reverse = true
+
+[colors]
+pink = "#ff0000"
+border = "8"
+
+[scoring]
+prefix_bonus = 10.5
+
+[files]
+skip_dirs = ["node_modules"]
+
+[preview]
+history_context_after = 0
+
+[git]
+worktree_path = "{root}/.worktrees/{branch}"
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := cfg.Keys["mode.files"]; !slices.Equal(got, []string{"ctrl+e"}) {
+		t.Errorf("keys mode.files = %q, want ctrl+e", got)
+	}
+	if got := cfg.Keys["select"]; !slices.Equal(got, []string{"enter", "ctrl+j"}) {
+		t.Errorf("keys select = %q, want enter and ctrl+j", got)
+	}
+	if want := (Layout{Preview: PreviewBottom, ListWidth: 50, PreviewHeight: 40, StackBelow: 80, Reverse: true}); cfg.Layout != want {
+		t.Errorf("layout = %+v, want %+v", cfg.Layout, want)
+	}
+	if cfg.Colors.Pink != "#ff0000" || cfg.Colors.Border != "8" || cfg.Colors.Cyan != Default().Colors.Cyan {
+		t.Errorf("colors = %+v, want pink and border changed only", cfg.Colors)
+	}
+	if cfg.Scoring.PrefixBonus != 10.5 || cfg.Scoring.MatchWeight != Default().Scoring.MatchWeight {
+		t.Errorf("scoring = %+v, want prefix_bonus changed only", cfg.Scoring)
+	}
+	if !slices.Equal(cfg.Files.SkipDirs, []string{"node_modules"}) {
+		t.Errorf("skip_dirs = %q, want node_modules", cfg.Files.SkipDirs)
+	}
+	if cfg.Preview.HistoryContextAfter != 0 || cfg.Preview.HistoryContextBefore != Default().Preview.HistoryContextBefore {
+		t.Errorf("preview = %+v, want history_context_after changed only", cfg.Preview)
+	}
+	if cfg.Git.WorktreePath != "{root}/.worktrees/{branch}" {
+		t.Errorf("worktree_path = %q, want the one set", cfg.Git.WorktreePath)
+	}
+}
+
+func TestResolveTheme(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantPink   string
+		wantCyan   string
+		wantChroma string
+	}{
+		{"default", ``, "#f7768e", "#7dcfff", "tokyonight"},
+		{"built-in", `theme = "gruvbox-light"`, "#9d0006", "#427b58", "gruvbox-light"},
+		{"colors override the theme", "theme = \"dracula\"\n[colors]\npink = \"1\"", "1", "#8be9fd", "dracula"},
+		{
+			"custom theme",
+			"theme = \"mine\"\n[themes.mine]\nbase = \"solarized-light\"\nchroma = \"github\"\npink = \"#000000\"",
+			"#000000", "#2aa198", "github",
+		},
+		{"custom theme without a base", "theme = \"mine\"\n[themes.mine]\npink = \"5\"", "5", "#7dcfff", "tokyonight"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse(tt.data)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			theme, err := cfg.ResolveTheme()
+			if err != nil {
+				t.Fatalf("ResolveTheme() error = %v", err)
+			}
+			if theme.Pink != tt.wantPink || theme.Cyan != tt.wantCyan || theme.Chroma != tt.wantChroma {
+				t.Errorf("theme = pink %q, cyan %q, chroma %q; want %q, %q, %q",
+					theme.Pink, theme.Cyan, theme.Chroma, tt.wantPink, tt.wantCyan, tt.wantChroma)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"syntax", "[layout\nlist_width = 50", "line 2"},
+		{"unknown section", "[colours]\npink = \"#ff0000\"", `"colours"`},
+		{"unknown key", "[layout]\nlist_wdth = 50", `"layout.list_wdth"`},
+		{"wrong type", "[layout]\nlist_width = \"wide\"", "list_width"},
+		{"out of range", "[layout]\nlist_width = 95", "between 10 and 90"},
+		{"bad preview place", "[layout]\npreview = \"left\"", "layout.preview"},
+		{"negative stack width", "[layout]\nstack_below = -1", "layout.stack_below"},
+		{"bad color", "[colors]\npink = \"red\"", "colors.pink"},
+		{"bad key", "[keys]\nselect = 1", "keys.select"},
+		{"unknown theme", `theme = "nord"`, `unknown theme "nord"`},
+		{"unknown base", "[themes.mine]\nbase = \"nord\"", "themes.mine.base"},
+		{"unknown chroma style", "[themes.mine]\nchroma = \"nope\"", "themes.mine.chroma"},
+		{"bad theme color", "[themes.mine]\npink = \"red\"", "themes.mine.pink"},
+		{"unknown secrets rule", "[secrets]\ndisable = [\"aws\"]", `unknown built-in rule "aws"`},
+		{"secrets rule without a pattern", "[[secrets.rules]]\nname = \"mine\"", "secrets.rules[0]"},
+		{"worktree path without the branch", "[git]\nworktree_path = \"/tmp/wt\"", "git.worktree_path"},
+		{"bad secrets pattern", "[[secrets.rules]]\nname = \"mine\"\npattern = \"(\"", `rule "mine"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want one mentioning %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecrets_Redactor(t *testing.T) {
+	cfg, err := Parse(`
+[secrets]
+disable = ["mysql-password"]
+
+[[secrets.rules]]
+name = "internal-token"
+pattern = 'itk_[a-z0-9]+'
+keywords = ["itk_"]
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	r, err := cfg.Secrets.Redactor()
+	if err != nil {
+		t.Fatalf("Redactor() error = %v", err)
+	}
+	if got, _ := r.Redact("deploy --key itk_abc123"); got != "deploy --key ****" {
+		t.Errorf("Redact() with a custom rule = %q, want the token masked", got)
+	}
+	if got, _ := r.Redact("mysql -phunter2"); got != "mysql -phunter2" {
+		t.Errorf("Redact() with a disabled rule = %q, want it unchanged", got)
+	}
+
+	cfg.Secrets.Redact = false
+	if r, err := cfg.Secrets.Redactor(); r != nil || err != nil {
+		t.Errorf("Redactor() with redact = false = %v, %v; want nil, nil", r, err)
+	}
+}
+
+func TestLoad_MissingFileGivesDefaults(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "config.toml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Layout != Default().Layout {
+		t.Errorf("Load() = %+v, want the defaults", cfg)
+	}
+}
+
+func TestLoad_NamesTheFileInErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("bogus = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(path)
+	if err == nil || !strings.HasPrefix(err.Error(), path+": ") {
+		t.Errorf("Load() error = %v, want it to start with the path", err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("FUZZ_FISH_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if got, want := Path(), filepath.Join("/xdg", "fuzz.fish", "config.toml"); got != want {
+		t.Errorf("Path() = %q, want %q", got, want)
+	}
+	t.Setenv("FUZZ_FISH_CONFIG", "/etc/fuzz.toml")
+	if got := Path(); got != "/etc/fuzz.toml" {
+		t.Errorf("Path() = %q, want $FUZZ_FISH_CONFIG", got)
+	}
+}
@@ -1,88 +1,335 @@
 package files
 
 import (
-	"io/fs"
+	"context"
+	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
+// DefaultMaxFiles is how many entries a Collector returns by default. It only
+// guards against walking an entire disk by accident; a large monorepo fits.
+const DefaultMaxFiles = 500000
+
+// batchInterval is how often Stream sends the entries found so far.
+const batchInterval = 100 * time.Millisecond
+
 // Collector walks the directory tree and collects files and directories
 type Collector struct {
 	Root     string
 	MaxFiles int
 	SkipDirs map[string]bool
+	Workers  int  // directories read concurrently
+	Hidden   bool // include hidden files and directories
+	NoIgnore bool // do not honour .gitignore, .ignore and .fdignore files
+}
+
+// DefaultSkipDirs are the directories NewCollector skips: VCS metadata,
+// dependencies and build output, which are large and rarely searched for.
+var DefaultSkipDirs = []string{
+	".git",
+	"node_modules",
+	"vendor",
+	"__pycache__",
+	".venv",
+	"venv",
+	".cache",
+	"dist",
+	"build",
+	".next",
+	".nuxt",
+	"target", // Rust/Java
 }
 
 // NewCollector creates a Collector with sensible defaults
 func NewCollector(root string) *Collector {
-	return &Collector{
+	c := &Collector{
 		Root:     root,
-		MaxFiles: 5000,
-		SkipDirs: map[string]bool{
-			".git":         true,
-			"node_modules": true,
-			"vendor":       true,
-			"__pycache__":  true,
-			".venv":        true,
-			"venv":         true,
-			".cache":       true,
-			"dist":         true,
-			"build":        true,
-			".next":        true,
-			".nuxt":        true,
-			"target":       true, // Rust/Java
-		},
+		MaxFiles: DefaultMaxFiles,
+		Workers:  max(4, runtime.NumCPU()),
 	}
+	c.SetSkipDirs(DefaultSkipDirs)
+	return c
 }
 
-// Collect walks the directory tree and returns all collected entries
+// SetSkipDirs replaces the directories skipped by name.
+func (c *Collector) SetSkipDirs(names []string) {
+	c.SkipDirs = make(map[string]bool, len(names))
+	for _, name := range names {
+		c.SkipDirs[name] = true
+	}
+}
+
+// Collect walks the directory tree and returns all collected entries, in the
+// order Stream sends them.
 func (c *Collector) Collect() []Entry {
+	out := make(chan []Entry)
+	go c.Stream(context.Background(), out)
+
 	// Pre-allocate with a reasonable initial capacity to reduce re-allocations
 	files := make([]Entry, 0, 512)
+	for batch := range out {
+		files = append(files, batch...)
+	}
+	return files
+}
+
+// Stream walks the directory tree, reading several directories at once, and
+// sends the entries found to out in batches. Entries come in the same order
+// on every run: breadth-first, shallow entries first, and by name within a
+// directory. out is closed when the walk ends, MaxFiles entries have been
+// sent or ctx is cancelled.
+func (c *Collector) Stream(ctx context.Context, out chan<- []Entry) {
+	defer close(out)
+
+	found := make(chan Entry, 1024)
+	go func() {
+		c.walk(ctx, found)
+		close(found)
+	}()
 
-	_ = filepath.WalkDir(c.Root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // Skip errors
+	sendBatches(ctx, found, out)
+}
+
+// sendBatches sends the values read from found to out in batches, every
+// batchInterval, until found is closed or ctx is cancelled.
+func sendBatches[T any](ctx context.Context, found <-chan T, out chan<- []T) {
+	var batch []T
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	send := func() bool {
+		if len(batch) == 0 {
+			return true
 		}
+		select {
+		case out <- batch:
+			batch = nil
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for {
+		select {
+		case v, ok := <-found:
+			if !ok {
+				send()
+				return
+			}
+			batch = append(batch, v)
+		case <-ticker.C:
+			if !send() {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// walk reads directories breadth-first with c.Workers goroutines and sends
+// every entry that is not skipped to found, at most MaxFiles of them.
+// Directories are read concurrently, but their entries are sent in the order
+// a walk reading one directory at a time would find them, so neither the
+// order nor the entries cut off by MaxFiles depend on timing.
+func (c *Collector) walk(ctx context.Context, found chan<- Entry) {
+	var rules ignoreRules
+	var prefix []string
+	if !c.NoIgnore {
+		rules, prefix = rootIgnoreRules(c.Root)
+	}
+	q := newDirQueue(queuedDir{path: ".", components: prefix, rules: rules})
+	// Wake idle workers so they notice the cancellation.
+	defer context.AfterFunc(ctx, q.close)()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// Stop the workers before waiting for them, however the walk ends.
+	defer q.close()
+	for range max(1, c.Workers) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				seq, dir, ok := q.pop()
+				if !ok {
+					return
+				}
+				entries, subdirs := c.readDir(dir)
+				q.done(seq, dirResult{entries: entries, subdirs: subdirs})
+			}
+		}()
+	}
 
-		// Check file limit
-		if len(files) >= c.MaxFiles {
-			return filepath.SkipAll
+	count := 0
+	for {
+		res, ok := q.next()
+		if !ok {
+			return
+		}
+		for _, e := range res.entries {
+			if count == c.MaxFiles {
+				return
+			}
+			count++
+			select {
+			case found <- e:
+			case <-ctx.Done():
+				return
+			}
 		}
+		q.push(res.subdirs)
+	}
+}
+
+// readDir returns the entries of dir that are not skipped and its
+// subdirectories to walk.
+func (c *Collector) readDir(dir queuedDir) (found []Entry, subdirs []queuedDir) {
+	fullPath := filepath.Join(c.Root, dir.path)
+	entries, err := os.ReadDir(fullPath)
+	if err != nil {
+		return nil, nil // Skip errors
+	}
+
+	rules := dir.rules
+	if !c.NoIgnore {
+		rules = rules.forDir(fullPath, dir.components)
+	}
+
+	for _, d := range entries {
+		name := d.Name()
 
 		// Skip known heavy directories
-		if d.IsDir() {
-			if c.SkipDirs[d.Name()] {
-				return filepath.SkipDir
-			}
+		if d.IsDir() && c.SkipDirs[name] {
+			continue
 		}
 
-		// Skip hidden files/directories (except current dir)
-		if path != c.Root && strings.HasPrefix(d.Name(), ".") {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
+		// Skip hidden files/directories
+		if !c.Hidden && strings.HasPrefix(name, ".") {
+			continue
 		}
 
-		// Get relative path
-		relPath, err := filepath.Rel(c.Root, path)
-		if err != nil {
-			relPath = path
+		components := make([]string, len(dir.components)+1)
+		copy(components, dir.components)
+		components[len(dir.components)] = name
+		if rules.ignored(components, d.IsDir()) {
+			continue
 		}
 
-		// Skip current directory itself
-		if relPath == "." {
-			return nil
+		relPath := filepath.Join(dir.path, name)
+		found = append(found, Entry{Path: relPath, IsDir: d.IsDir()})
+		if d.IsDir() {
+			subdirs = append(subdirs, queuedDir{path: relPath, components: components, rules: rules})
 		}
+	}
+	return found, subdirs
+}
+
+// queuedDir is a directory left to read: its path relative to the collector
+// root, its path components relative to the ignore rules' base and the rules
+// inherited from its parents.
+type queuedDir struct {
+	path       string
+	components []string
+	rules      ignoreRules
+}
 
-		files = append(files, Entry{
-			Path:  relPath,
-			IsDir: d.IsDir(),
-		})
+// dirResult is what reading a directory found.
+type dirResult struct {
+	entries []Entry
+	subdirs []queuedDir
+}
 
-		return nil
-	})
+// readAhead is how many directories the walk workers may read past the one
+// whose entries are to be sent next, which bounds the results held in memory
+// while the entries are consumed slowly.
+const readAhead = 1024
 
-	return files
+// dirQueue hands the directories left to read to the walk workers in walk
+// order, numbering them as they are queued, and gives their results back in
+// the same order. The walk is over when every queued directory has been
+// given back.
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	dirs    []queuedDir       // Left to read, in walk order
+	head    int               // Number of the first of dirs
+	results map[int]dirResult // Read but not yet given back, by number
+	sent    int               // Number of the directory to give back next
+	closed  bool
+}
+
+// newDirQueue returns a queue holding root, the first directory to read.
+func newDirQueue(root queuedDir) *dirQueue {
+	q := &dirQueue{dirs: []queuedDir{root}, results: make(map[int]dirResult)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// pop waits for a directory to read and returns it with its number; ok is
+// false once the queue is closed.
+func (q *dirQueue) pop() (seq int, dir queuedDir, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.closed && (len(q.dirs) == 0 || q.head-q.sent >= readAhead) {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return 0, queuedDir{}, false
+	}
+	seq, dir = q.head, q.dirs[0]
+	q.dirs = q.dirs[1:]
+	q.head++
+	return seq, dir, true
+}
+
+// done stores the result of reading directory seq.
+func (q *dirQueue) done(seq int, res dirResult) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.results[seq] = res
+	q.cond.Broadcast()
+}
+
+// next waits for the result of the next directory in walk order; ok is false
+// once every directory has been given back or the queue is closed.
+func (q *dirQueue) next() (res dirResult, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.closed {
+			return dirResult{}, false
+		}
+		if res, ok = q.results[q.sent]; ok {
+			delete(q.results, q.sent)
+			q.sent++
+			q.cond.Broadcast()
+			return res, true
+		}
+		// Every directory queued so far has been given back, so no worker
+		// can queue more.
+		if q.sent == q.head && len(q.dirs) == 0 {
+			return dirResult{}, false
+		}
+		q.cond.Wait()
+	}
+}
+
+// push queues the subdirectories of the directory last given back by next.
+func (q *dirQueue) push(subdirs []queuedDir) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dirs = append(q.dirs, subdirs...)
+	q.cond.Broadcast()
+}
+
+// close ends the walk early.
+func (q *dirQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
 }
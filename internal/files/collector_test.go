@@ -0,0 +1,182 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeTree creates the given files (and their parent directories) under a
+// temporary directory and returns it.
+func writeTree(t *testing.T, paths ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, p := range paths {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestCollector_Collect(t *testing.T) {
+	root := writeTree(t,
+		"a.go",
+		"src/b.go",
+		"src/deep/c.go",
+		"node_modules/pkg/index.js",
+		".hidden/secret",
+		"src/.env",
+	)
+
+	got := NewCollector(root).Collect()
+
+	want := []Entry{
+		{Path: "a.go"},
+		{Path: "src", IsDir: true},
+		{Path: filepath.Join("src", "b.go")},
+		{Path: filepath.Join("src", "deep"), IsDir: true},
+		{Path: filepath.Join("src", "deep", "c.go")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Collect() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCollector_MaxFiles(t *testing.T) {
+	root := writeTree(t, "1", "2", "3", "d/4", "d/5")
+	c := NewCollector(root)
+	c.MaxFiles = 3
+
+	if got := c.Collect(); len(got) != 3 {
+		t.Errorf("Collect() returned %d entries, want MaxFiles (3)", len(got))
+	}
+}
+
+func TestCollector_StreamOrderIsStable(t *testing.T) {
+	root := writeTree(t, "b/2", "a/x/1", "a/y", "c", "b/z/3", "a/x/0")
+	want := "a b c a/x a/y b/2 b/z a/x/0 a/x/1 b/z/3"
+	for range 20 {
+		c := NewCollector(root)
+		c.Workers = 8
+		out := make(chan []Entry)
+		go c.Stream(context.Background(), out)
+		var got []Entry
+		for batch := range out {
+			got = append(got, batch...)
+		}
+		if s := strings.Join(paths(got), " "); s != want {
+			t.Fatalf("Stream() = %s\nwant       %s", s, want)
+		}
+	}
+}
+
+func TestCollector_StreamStopsWhenCancelled(t *testing.T) {
+	root := writeTree(t, "a/1", "b/2", "c/3")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	out := make(chan []Entry)
+	go NewCollector(root).Stream(ctx, out)
+	for range out {
+		// Entries found before the cancellation was noticed may still arrive;
+		// the channel must be closed either way.
+	}
+}
+
+// paths returns the paths of entries, for comparing collections.
+func paths(entries []Entry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = filepath.ToSlash(e.Path)
+	}
+	return out
+}
+
+func TestCollector_HonoursIgnoreFiles(t *testing.T) {
+	root := writeTree(t,
+		".git/info/exclude",
+		"keep.go",
+		"debug.log",
+		"important.log",
+		"excluded.tmp",
+		"global.bak",
+		"dotignored.txt",
+		"fdignored.txt",
+		"sub/local.gen",
+		"sub/deep/other.gen",
+		"sub/keep.gen",
+		"other/local.gen",
+		".hidden",
+	)
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(path)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "# comment\n*.log\n!important.log\n")
+	write("sub/.gitignore", "*.gen\n!keep.gen\n")
+	write(".git/info/exclude", "*.tmp\n")
+	write(".ignore", "dotignored.txt\n")
+	write(".fdignore", "fdignored.txt\n")
+
+	global := filepath.Join(t.TempDir(), "ignore")
+	if err := os.WriteFile(global, []byte("*.bak\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitconfig := filepath.Join(t.TempDir(), "gitconfig")
+	if err := os.WriteFile(gitconfig, []byte("[core]\n\texcludesFile = "+global+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", gitconfig)
+
+	got := strings.Join(paths(NewCollector(root).Collect()), " ")
+	want := "important.log keep.go other sub other/local.gen sub/deep sub/keep.gen"
+	if got != want {
+		t.Errorf("Collect() = %s\nwant        %s", got, want)
+	}
+
+	// Collecting from a subdirectory still applies the ignore files above it.
+	got = strings.Join(paths(NewCollector(filepath.Join(root, "sub")).Collect()), " ")
+	if want := "deep keep.gen"; got != want {
+		t.Errorf("Collect() in sub = %s, want %s", got, want)
+	}
+
+	c := NewCollector(root)
+	c.NoIgnore = true
+	c.Hidden = true
+	all := paths(c.Collect())
+	for _, p := range []string{".hidden", ".gitignore", "debug.log", "sub/local.gen", "global.bak"} {
+		if !slices.Contains(all, p) {
+			t.Errorf("Collect() with NoIgnore and Hidden is missing %s", p)
+		}
+	}
+	if slices.Contains(all, ".git") {
+		t.Error("Collect() with NoIgnore and Hidden listed .git")
+	}
+}
+
+func TestCollector_HonoursAncestorIgnoreFilesOutsideRepo(t *testing.T) {
+	root := writeTree(t, "sub/keep.go", "sub/notes.txt", "sub/deep/.gitignore", "sub/deep/skip.txt")
+	if err := os.WriteFile(filepath.Join(root, ".ignore"), []byte("*.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Join(paths(NewCollector(filepath.Join(root, "sub")).Collect()), " ")
+	if want := "deep keep.go"; got != want {
+		t.Errorf("Collect() in sub = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jedipunkz/fuzz.fish/internal/ui"
+)
+
+// MaxMatches is how many matches a content search returns at most. Past that
+// the query is too broad for the list to be useful anyway.
+const MaxMatches = 10000
+
+// maxLineBytes is the longest line searched; the rest of a file with a longer
+// line (typically minified code) is skipped.
+const maxLineBytes = 1024 * 1024
+
+// Match is a line of a file that contains the searched text
+type Match struct {
+	Path string // relative to the collector root
+	Line int    // 1-based line number
+	Col  int    // byte offset of the match in Text
+	Len  int    // byte length of the match
+	Text string // the whole line
+}
+
+// Grep searches the contents of the files the collector walks for query and
+// sends the matching lines to out in batches. The search is literal and
+// ignores case unless query has an upper case letter. Binary files are
+// skipped. out is closed when the search ends, MaxMatches matches have been
+// sent or ctx is cancelled.
+func (c *Collector) Grep(ctx context.Context, query string, out chan<- []Match) {
+	defer close(out)
+
+	pattern := grepPattern(query)
+
+	// grepCtx also stops the search once MaxMatches matches are found, while
+	// the matches already found are still sent.
+	grepCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	entries := make(chan Entry, 1024)
+	go func() {
+		// MaxFiles only ends the walk; the files found are still searched.
+		c.walk(grepCtx, entries)
+		close(entries)
+	}()
+
+	found := make(chan Match, 1024)
+	go func() {
+		var count atomic.Int64
+		var wg sync.WaitGroup
+		for range max(1, c.Workers) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for e := range entries {
+					if e.IsDir || grepCtx.Err() != nil {
+						continue
+					}
+					grepFile(filepath.Join(c.Root, e.Path), e.Path, pattern, func(m Match) bool {
+						if count.Add(1) > MaxMatches {
+							stop()
+							return false
+						}
+						select {
+						case found <- m:
+							return true
+						case <-grepCtx.Done():
+							return false
+						}
+					})
+				}
+			}()
+		}
+		wg.Wait()
+		close(found)
+	}()
+
+	sendBatches(ctx, found, out)
+}
+
+// grepPattern returns the pattern matching query literally, ignoring case
+// when query is all lower case (smart case, as in ripgrep and fd).
+func grepPattern(query string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(query)
+	if strings.ToLower(query) == query {
+		expr = "(?i)" + expr
+	}
+	return regexp.MustCompile(expr)
+}
+
+// grepFile calls emit with the first match of pattern on every line of the
+// file at path, until emit returns false. rel is the path reported in the
+// matches.
+func grepFile(path, rel string, pattern *regexp.Regexp, emit func(Match) bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	r := bufio.NewReaderSize(f, ui.BinaryDetectionBytes)
+	// A short file makes Peek return an error along with all of its content.
+	head, _ := r.Peek(ui.BinaryDetectionBytes)
+	if ui.IsBinary(head) {
+		return
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineBytes)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		loc := pattern.FindIndex(line)
+		if loc == nil {
+			continue
+		}
+		text := strings.TrimSuffix(string(line), "\r")
+		if loc[1] > len(text) {
+			loc[1] = len(text)
+		}
+		if !emit(Match{Path: rel, Line: n, Col: loc[0], Len: loc[1] - loc[0], Text: text}) {
+			return
+		}
+	}
+}
+
+// GeneratePreview generates a preview of the lines around the match for the
+// TUI preview window, syntax highlighted, with the matched text highlighted
+func (m Match) GeneratePreview(width, height int) string {
+	var sb strings.Builder
+
+	// Location
+	sb.WriteString(ui.LabelStyle.Render(fmt.Sprintf("%s:%d", m.Path, m.Line)) + "\n\n")
+
+	// Center the match in the lines left below the location.
+	count := max(1, height-2)
+	first := max(1, m.Line-(count-1)/2)
+	lines := readLines(m.Path, first, first+count-1)
+	if len(lines) == 0 {
+		sb.WriteString(ui.InactiveContextStyle.Render("  (file no longer readable)") + "\n")
+		return sb.String()
+	}
+
+	// Highlight the lines together so tokens spanning lines are lexed right;
+	// keep them plain if the highlighter does not preserve the line breaks.
+	highlighted := lines
+	if code, err := ui.HighlightCode(strings.Join(lines, "\n"), m.Path); err == nil {
+		if split := strings.Split(code, "\n"); len(split) >= len(lines) {
+			highlighted = split[:len(lines)]
+		}
+	}
+
+	numWidth := len(fmt.Sprint(first + len(lines) - 1))
+	contentWidth := width - numWidth - 3
+	for i, line := range lines {
+		n := first + i
+		num := fmt.Sprintf("%*d", numWidth, n)
+		if n == m.Line {
+			sb.WriteString(ui.ActiveContextStyle.Render("▶ "+num) + " ")
+			sb.WriteString(ui.PreviewLine(m.highlightLine(line), contentWidth))
+		} else {
+			sb.WriteString(ui.InactiveContextStyle.Render("  "+num) + " ")
+			sb.WriteString(ui.PreviewLine(highlighted[i], contentWidth))
+		}
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+// highlightLine renders line, the matched line as read for the preview, with
+// the matched text highlighted. The file may have changed since the search, so
+// the match is only highlighted while it still fits the line.
+func (m Match) highlightLine(line string) string {
+	end := m.Col + m.Len
+	if m.Len == 0 || end > len(line) || line[m.Col:end] != m.Text[m.Col:end] {
+		return ui.ContentStyle.Render(line)
+	}
+	return ui.ContentStyle.Render(line[:m.Col]) +
+		ui.MatchStyle.Render(line[m.Col:end]) +
+		ui.ContentStyle.Render(line[end:])
+}
+
+// readLines returns lines first to last (1-based) of the file at path, fewer
+// when the file is shorter.
+func readLines(path string, first, last int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxLineBytes)
+	for n := 1; n <= last && scanner.Scan(); n++ {
+		if n >= first {
+			lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,86 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// grep runs a content search under root and returns its matches sorted by
+// path and line.
+func grep(t *testing.T, root, query string) []Match {
+	t.Helper()
+	out := make(chan []Match)
+	go NewCollector(root).Grep(context.Background(), query, out)
+
+	var matches []Match
+	for batch := range out {
+		matches = append(matches, batch...)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Line < matches[j].Line
+	})
+	return matches
+}
+
+func TestCollector_Grep(t *testing.T) {
+	root := writeTree(t, "a.go", "sub/b.txt", "node_modules/c.js", "bin.dat", "ignored.txt", ".gitignore")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(path)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.go", "package a\n\n\tfunc Hello() {}\n")
+	write("sub/b.txt", "say hello\r\nnothing here\nHELLO again\n")
+	write("node_modules/c.js", "hello from a skipped dir\n")
+	write("bin.dat", "hello\x00binary\n")
+	write("ignored.txt", "hello from an ignored file\n")
+	write(".gitignore", "ignored.txt\n")
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	got := grep(t, root, "hello")
+	want := []Match{
+		{Path: "a.go", Line: 3, Col: 6, Len: 5, Text: "\tfunc Hello() {}"},
+		{Path: filepath.Join("sub", "b.txt"), Line: 1, Col: 4, Len: 5, Text: "say hello"},
+		{Path: filepath.Join("sub", "b.txt"), Line: 3, Col: 0, Len: 5, Text: "HELLO again"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Grep(hello) = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("match %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// An upper case letter makes the search case sensitive.
+	got = grep(t, root, "HELLO")
+	if len(got) != 1 || got[0].Text != "HELLO again" {
+		t.Errorf("Grep(HELLO) = %+v, want only the upper case line", got)
+	}
+}
+
+func TestMatch_GeneratePreviewHighlightsMatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthe needle line\nfour\nfive\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := Match{Path: path, Line: 3, Col: 4, Len: 6, Text: "the needle line"}
+	preview := m.GeneratePreview(80, 20)
+	for _, want := range []string{"two", "needle", "four", "▶ 3"} {
+		if !strings.Contains(preview, want) {
+			t.Errorf("GeneratePreview() is missing %q:\n%s", want, preview)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package files
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// gitIgnoreFile is only honoured inside a git repository, like git itself
+// does. The other ignore files follow it in increasing priority, as in fd and
+// ripgrep.
+const gitIgnoreFile = ".gitignore"
+
+var extraIgnoreFiles = []string{".ignore", ".fdignore"}
+
+// ignoreRules holds the ignore patterns in effect for a directory. Paths are
+// matched as components relative to base: the repository root inside a git
+// repository, the filesystem root otherwise.
+type ignoreRules struct {
+	patterns []gitignore.Pattern
+	inRepo   bool
+}
+
+// ignored reports whether the path, given as components relative to the
+// rules' base, is excluded.
+func (r ignoreRules) ignored(path []string, isDir bool) bool {
+	if len(r.patterns) == 0 {
+		return false
+	}
+	return gitignore.NewMatcher(r.patterns).Match(path, isDir)
+}
+
+// forDir returns the rules for the directory whose components relative to
+// the base are domain, adding the ignore files found in it. The receiver's
+// patterns are not modified, since sibling directories share them.
+func (r ignoreRules) forDir(dir string, domain []string) ignoreRules {
+	var added []gitignore.Pattern
+	if r.inRepo {
+		added = append(added, readIgnoreFile(filepath.Join(dir, gitIgnoreFile), domain)...)
+	}
+	for _, name := range extraIgnoreFiles {
+		added = append(added, readIgnoreFile(filepath.Join(dir, name), domain)...)
+	}
+	if len(added) == 0 {
+		return r
+	}
+	patterns := make([]gitignore.Pattern, 0, len(r.patterns)+len(added))
+	patterns = append(patterns, r.patterns...)
+	patterns = append(patterns, added...)
+	return ignoreRules{patterns: patterns, inRepo: r.inRepo}
+}
+
+// rootIgnoreRules returns the rules in effect above root and the components
+// of root relative to the rules' base. Inside a git repository these are the
+// global excludes file, the repository's info/exclude and the ignore files of
+// root's ancestors up to the repository root. Outside one they are the
+// .ignore and .fdignore files of root's ancestors up to the filesystem root,
+// as fd reads them.
+func rootIgnoreRules(root string) (ignoreRules, []string) {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+
+	var rules ignoreRules
+	base, gitDir := findRepo(root)
+	if base != "" {
+		rules.inRepo = true
+		if global := globalExcludesFile(); global != "" {
+			rules.patterns = append(rules.patterns, readIgnoreFile(global, nil)...)
+		}
+		rules.patterns = append(rules.patterns, readIgnoreFile(filepath.Join(gitDir, "info", "exclude"), nil)...)
+	} else {
+		base = filepath.VolumeName(root) + string(filepath.Separator)
+	}
+
+	rel, err := filepath.Rel(base, root)
+	if err != nil || rel == "." {
+		return rules, nil
+	}
+	prefix := strings.Split(rel, string(filepath.Separator))
+
+	// Ancestors of root up to the base; root's own ignore files are read
+	// when the walk reads root.
+	dir := base
+	for i := range prefix {
+		rules = rules.forDir(dir, prefix[:i])
+		dir = filepath.Join(dir, prefix[i])
+	}
+	return rules, prefix
+}
+
+// findRepo returns the root of the git repository containing dir and its git
+// directory holding info/exclude, or empty strings outside a repository.
+func findRepo(dir string) (root, gitDir string) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return dir, dotGit
+			}
+			// Linked worktrees and submodules have a .git file pointing at
+			// their git directory.
+			return dir, linkedGitDir(dir, dotGit)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// linkedGitDir resolves the "gitdir: <path>" line of a .git file. For a
+// linked worktree, info/exclude lives in the common git directory.
+func linkedGitDir(dir, dotGit string) string {
+	content, err := os.ReadFile(dotGit)
+	if err != nil {
+		return dotGit
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(string(content), "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		c := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(c) {
+			c = filepath.Join(gitDir, c)
+		}
+		return filepath.Clean(c)
+	}
+	return gitDir
+}
+
+// globalExcludesFile returns git's core.excludesFile, or its default
+// $XDG_CONFIG_HOME/git/ignore.
+func globalExcludesFile() string {
+	out, err := exec.Command("git", "config", "--get", "core.excludesFile").Output()
+	if path := strings.TrimSpace(string(out)); err == nil && path != "" {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+		return path
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "git", "ignore")
+	}
+	return ""
+}
+
+// readIgnoreFile parses the patterns of the ignore file at path, scoped to
+// domain. A missing file has no patterns.
+func readIgnoreFile(path string, domain []string) []gitignore.Pattern {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	return patterns
+}
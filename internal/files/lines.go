@@ -0,0 +1,50 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamLines reads r line by line (e.g. the output of a command piped into
+// fuzz) and sends the lines to out in batches, like Stream, until r is
+// exhausted or ctx is cancelled. out is closed afterwards. Line endings are
+// dropped, including the carriage return of CRLF. It returns why reading
+// ended early: a read error, a line longer than maxLineBytes (wrapping
+// bufio.ErrTooLong) or the cancellation.
+func StreamLines(ctx context.Context, r io.Reader, out chan<- []string) error {
+	defer close(out)
+
+	found := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(found)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxLineBytes)
+		n := 0
+		for scanner.Scan() {
+			n++
+			select {
+			case found <- strings.TrimSuffix(scanner.Text(), "\r"):
+			case <-ctx.Done():
+				return
+			}
+		}
+		err := scanner.Err()
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("line %d is longer than %d bytes: %w", n+1, maxLineBytes, err)
+		}
+		errc <- err
+	}()
+
+	sendBatches(ctx, found, out)
+	select {
+	case err := <-errc:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
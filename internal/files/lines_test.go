@@ -0,0 +1,49 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStreamLines(t *testing.T) {
+	out := make(chan []string)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- StreamLines(context.Background(), strings.NewReader("one\r\n\ttwo  \n\nlast without newline"), out)
+	}()
+
+	var lines []string
+	for batch := range out {
+		lines = append(lines, batch...)
+	}
+	want := []string{"one", "\ttwo  ", "", "last without newline"}
+	if !slices.Equal(lines, want) {
+		t.Errorf("StreamLines() = %q, want %q", lines, want)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("StreamLines() returned unexpected error: %v", err)
+	}
+}
+
+func TestStreamLines_ReportsTooLongLine(t *testing.T) {
+	input := "short\n" + strings.Repeat("x", maxLineBytes+1) + "\nafter\n"
+	out := make(chan []string)
+	errc := make(chan error, 1)
+	go func() { errc <- StreamLines(context.Background(), strings.NewReader(input), out) }()
+
+	var lines []string
+	for batch := range out {
+		lines = append(lines, batch...)
+	}
+	if want := []string{"short"}; !slices.Equal(lines, want) {
+		t.Errorf("StreamLines() = %q, want %q", lines, want)
+	}
+	err := <-errc
+	if !errors.Is(err, bufio.ErrTooLong) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("StreamLines() error = %v, want bufio.ErrTooLong for line 2", err)
+	}
+}
@@ -0,0 +1,47 @@
+package git
+
+import "strings"
+
+// CreateBranch creates a local branch named name starting at base, or at HEAD
+// when base is empty. A remote-tracking base sets it up as the upstream.
+func (r *Repository) CreateBranch(name, base string) error {
+	args := []string{"branch", "--", name}
+	if base != "" {
+		args = append(args, base)
+	}
+	_, err := r.runGit(args...)
+	return err
+}
+
+// RenameBranch renames the local branch oldName to newName. It refuses to
+// overwrite an existing branch.
+func (r *Repository) RenameBranch(oldName, newName string) error {
+	_, err := r.runGit("branch", "--move", "--", oldName, newName)
+	return err
+}
+
+// DeleteBranch deletes the local branch name. Without force git refuses to
+// delete a branch that is not merged.
+func (r *Repository) DeleteBranch(name string, force bool) error {
+	flag := "--delete"
+	if force {
+		flag = "-D"
+	}
+	_, err := r.runGit("branch", flag, "--", name)
+	return err
+}
+
+// IsMerged reports whether the local branch name is merged into HEAD, i.e.
+// whether deleting it loses no commits that HEAD does not have.
+func (r *Repository) IsMerged(name string) (bool, error) {
+	out, err := r.runGit("branch", "--format=%(refname:short)", "--merged", "HEAD")
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if line == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
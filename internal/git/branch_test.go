@@ -0,0 +1,71 @@
+package git
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// initTestRepo creates a repository with a single commit on main and returns
+// its path. The test is skipped when git is not available.
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestBranchLifecycle(t *testing.T) {
+	r := NewRepository(initTestRepo(t))
+
+	if err := r.CreateBranch("topic", ""); err != nil {
+		t.Fatalf("CreateBranch() returned unexpected error: %v", err)
+	}
+	if err := r.CreateBranch("topic", "main"); err == nil {
+		t.Error("CreateBranch() of an existing branch returned no error")
+	}
+	if err := r.RenameBranch("topic", "feature/topic"); err != nil {
+		t.Fatalf("RenameBranch() returned unexpected error: %v", err)
+	}
+
+	merged, err := r.IsMerged("feature/topic")
+	if err != nil {
+		t.Fatalf("IsMerged() returned unexpected error: %v", err)
+	}
+	if !merged {
+		t.Error("IsMerged() = false for a branch at HEAD, want true")
+	}
+
+	if err := r.DeleteBranch("feature/topic", false); err != nil {
+		t.Fatalf("DeleteBranch() returned unexpected error: %v", err)
+	}
+	branches, err := r.Branches()
+	if err != nil {
+		t.Fatalf("Branches() returned unexpected error: %v", err)
+	}
+	for _, b := range branches {
+		if b.Name == "feature/topic" {
+			t.Error("deleted branch is still listed")
+		}
+	}
+}
+
+func TestDeleteBranch_ReportsGitError(t *testing.T) {
+	r := NewRepository(initTestRepo(t))
+
+	err := r.DeleteBranch("does-not-exist", false)
+	if err == nil {
+		t.Fatal("DeleteBranch() of a missing branch returned no error")
+	}
+	if err.Error() == "exit status 1" {
+		t.Errorf("DeleteBranch() error = %q, want git's message", err)
+	}
+}
@@ -0,0 +1,79 @@
+package git
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// MaxCommits caps how many commits Commits reads, so `--all` in a large
+// repository does not load its entire history into the list.
+const MaxCommits = 5000
+
+// Commit represents a single commit in the log
+type Commit struct {
+	Hash      string
+	ShortHash string
+	Author    string
+	Timestamp int64 // Unix committer timestamp
+	Subject   string
+	Refs      string // decorations, e.g. "HEAD -> main, origin/main"
+	Dir       string // repository directory it was listed in, where git show runs
+}
+
+// commitFormat separates fields with NUL; one commit per line, since %s is
+// the subject line only.
+const commitFormat = "%H%x00%h%x00%an%x00%ct%x00%s%x00%D"
+
+// Commits lists commits reachable from HEAD, newest first, or from every ref
+// when all is true. At most MaxCommits commits are returned.
+func (r *Repository) Commits(all bool) ([]Commit, error) {
+	args := []string{"log", "--format=" + commitFormat, "--max-count=" + strconv.Itoa(MaxCommits)}
+	if all {
+		args = append(args, "--all")
+	}
+	out, err := r.runGit(args...)
+	if err != nil {
+		return nil, err
+	}
+	commits := parseCommitLog(out)
+	dir := r.dir()
+	for i := range commits {
+		commits[i].Dir = dir
+	}
+	return commits, nil
+}
+
+// parseCommitLog parses `git log` output written with commitFormat.
+func parseCommitLog(out string) []Commit {
+	var commits []Commit
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 6 {
+			continue
+		}
+		ts, _ := strconv.ParseInt(fields[3], 10, 64)
+		commits = append(commits, Commit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Author:    fields[2],
+			Timestamp: ts,
+			Subject:   fields[4],
+			Refs:      fields[5],
+		})
+	}
+	return commits
+}
+
+// show returns the output of `git show` for the commit: the full message,
+// terminated by a NUL byte, followed by the stat and patch.
+func (c Commit) show(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "show", "--no-color", "--stat", "--patch", "--format=%B%x00", c.Hash)
+	cmd.Dir = c.Dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseCommitLog(t *testing.T) {
+	out := "7a2ca8c612543f4be2e3e0f56a895c123d77c8cd\x007a2ca8c\x00Alice\x001700000000\x00Fix parser: handle \"quotes\"\x00HEAD -> main, origin/main\n" +
+		"ce5e84bf40af34b3204673ae5c77241e1378bef9\x00ce5e84b\x00Bob\x001690000000\x00Initial commit\x00\n"
+
+	got := parseCommitLog(out)
+
+	want := []Commit{
+		{Hash: "7a2ca8c612543f4be2e3e0f56a895c123d77c8cd", ShortHash: "7a2ca8c", Author: "Alice", Timestamp: 1700000000, Subject: "Fix parser: handle \"quotes\"", Refs: "HEAD -> main, origin/main"},
+		{Hash: "ce5e84bf40af34b3204673ae5c77241e1378bef9", ShortHash: "ce5e84b", Author: "Bob", Timestamp: 1690000000, Subject: "Initial commit"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d commits, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("commit %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCommits_InGitRepo(t *testing.T) {
+	r := NewRepository(".")
+	if !r.IsRepo() {
+		t.Skip("not running in a git repository")
+	}
+
+	commits, err := r.Commits(false)
+	if err != nil {
+		t.Fatalf("Commits() returned unexpected error: %v", err)
+	}
+	if len(commits) == 0 {
+		t.Fatal("Commits() returned no commits in a valid git repo")
+	}
+	if len(commits) > MaxCommits {
+		t.Errorf("Commits() returned %d commits, want at most %d", len(commits), MaxCommits)
+	}
+	for _, c := range commits {
+		if c.Hash == "" || c.ShortHash == "" {
+			t.Errorf("commit %+v has an empty hash", c)
+		}
+	}
+}
+
+func TestCommitShow_RunsInItsRepository(t *testing.T) {
+	r := NewRepository(initTestRepo(t))
+	commits, err := r.Commits(false)
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("Commits() = %v, %v; want the initial commit", commits, err)
+	}
+
+	// Previews render after the current directory may have changed.
+	t.Chdir(t.TempDir())
+	out, err := commits[0].show(context.Background())
+	if err != nil || !strings.HasPrefix(out, "initial") {
+		t.Errorf("show() = %q, %v; want the initial commit", out, err)
+	}
+}
@@ -1,8 +1,13 @@
 package git
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
 	"strings"
 
+	"github.com/charmbracelet/x/ansi"
+	"github.com/jedipunkz/fuzz.fish/internal/files"
 	"github.com/jedipunkz/fuzz.fish/internal/ui"
 )
 
@@ -14,9 +19,37 @@ func (b Branch) GeneratePreview(width, height int) string {
 	sb.WriteString(ui.LabelStyle.Render("Branch") + "\n")
 	sb.WriteString(ui.ContentStyle.Render(b.Name) + "\n\n")
 
-	// Commit hash
+	// Commit hash, subject and author. Commit metadata is loaded in the
+	// background, so only the hash may be known yet.
 	sb.WriteString(ui.LabelStyle.Render("Commit") + "\n")
-	sb.WriteString(ui.ContentStyle.Render(b.LastCommit) + "\n\n")
+	sb.WriteString(ui.ContentStyle.Render(b.LastCommit) + "\n")
+	if b.LastCommitMessage != "" {
+		sb.WriteString(ui.ContentStyle.Render(ansi.Truncate(b.LastCommitMessage, width, "…")) + "\n")
+	}
+	if b.Author != "" {
+		sb.WriteString(ui.InactiveContextStyle.Render(b.Author) + "\n")
+	}
+	sb.WriteString("\n")
+
+	if b.CommitTimestamp > 0 {
+		sb.WriteString(ui.LabelStyle.Render("Date") + "\n")
+		sb.WriteString(ui.ContentStyle.Render(b.CommitDate) + "\n")
+		sb.WriteString(ui.ContentStyle.Render(ui.FormatRelativeTime(b.CommitTimestamp)) + "\n\n")
+	}
+
+	if b.Upstream != "" {
+		sb.WriteString(ui.LabelStyle.Render("Upstream") + "\n")
+		sb.WriteString(ui.ContentStyle.Render(b.Upstream) + "\n")
+		switch {
+		case b.UpstreamGone:
+			sb.WriteString(ui.InactiveContextStyle.Render("gone") + "\n")
+		case b.Ahead == 0 && b.Behind == 0:
+			sb.WriteString(ui.InactiveContextStyle.Render("up to date") + "\n")
+		default:
+			sb.WriteString(ui.InactiveContextStyle.Render(fmt.Sprintf("ahead %d, behind %d", b.Ahead, b.Behind)) + "\n")
+		}
+		sb.WriteString("\n")
+	}
 
 	// Type
 	sb.WriteString(ui.LabelStyle.Render("Type") + "\n")
@@ -31,7 +64,9 @@ func (b Branch) GeneratePreview(width, height int) string {
 	return sb.String()
 }
 
-// GeneratePreview generates a lightweight preview of the worktree
+// GeneratePreview generates a preview of the worktree: its branch, changed
+// files, upstream and recent commits. Details are loaded in the background,
+// so only the porcelain fields may be known yet.
 func (w Worktree) GeneratePreview(width, height int) string {
 	var sb strings.Builder
 
@@ -50,5 +85,194 @@ func (w Worktree) GeneratePreview(width, height int) string {
 		sb.WriteString(ui.ContentStyle.Render("Current worktree") + "\n")
 	}
 
+	if w.Locked {
+		sb.WriteString("\n")
+		sb.WriteString(ui.LabelStyle.Render("Locked") + "\n")
+		sb.WriteString(ui.ContentStyle.Render(orDefault(w.LockReason, "no reason given")) + "\n")
+	}
+	if w.Prunable {
+		sb.WriteString("\n")
+		sb.WriteString(ui.LabelStyle.Render("Prunable") + "\n")
+		sb.WriteString(ui.ContentStyle.Render(orDefault(w.PruneReason, "directory is missing")) + "\n")
+		return sb.String()
+	}
+
+	d := w.Details
+	if d == nil {
+		if w.Branch != "(bare)" {
+			sb.WriteString("\n" + ui.InactiveContextStyle.Render("  Loading status...") + "\n")
+		}
+		return sb.String()
+	}
+
+	if d.Modified > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(ui.LabelStyle.Render("Last modified") + "\n")
+		sb.WriteString(ui.ContentStyle.Render(ui.FormatTime(d.Modified)+" ("+ui.FormatRelativeTime(d.Modified)+")") + "\n")
+	}
+
+	if d.Status.Upstream != "" {
+		sb.WriteString("\n")
+		sb.WriteString(ui.LabelStyle.Render("Upstream") + "\n")
+		sb.WriteString(ui.ContentStyle.Render(d.Status.Upstream) + "\n")
+		if d.Status.Ahead == 0 && d.Status.Behind == 0 {
+			sb.WriteString(ui.InactiveContextStyle.Render("up to date") + "\n")
+		} else {
+			sb.WriteString(ui.InactiveContextStyle.Render(fmt.Sprintf("ahead %d, behind %d", d.Status.Ahead, d.Status.Behind)) + "\n")
+		}
+	}
+
+	sb.WriteString("\n")
+	if len(d.Status.Files) == 0 {
+		sb.WriteString(ui.ContextHeaderStyle.Render("Changes") + "\n")
+		sb.WriteString(ui.InactiveContextStyle.Render("  clean") + "\n")
+	} else {
+		sb.WriteString(ui.ContextHeaderStyle.Render(fmt.Sprintf("Changes (%d)", len(d.Status.Files))) + "\n")
+		for i, f := range d.Status.Files {
+			if i == ui.MaxWorktreeChanges {
+				sb.WriteString(ui.InactiveContextStyle.Render(fmt.Sprintf("  ... %d more", len(d.Status.Files)-i)) + "\n")
+				break
+			}
+			sb.WriteString(ui.ContentStyle.Render(ansi.Truncate(f.Code()+" "+f.Path, width, "…")) + "\n")
+		}
+	}
+
+	if len(d.Commits) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(ui.ContextHeaderStyle.Render("Recent commits") + "\n")
+		for _, c := range d.Commits {
+			line := c.ShortHash + " " + c.Subject + " (" + ui.FormatRelativeTime(c.Timestamp) + ")"
+			sb.WriteString(ui.ContentStyle.Render(ansi.Truncate(line, width, "…")) + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// GeneratePreview generates a preview of the commit: its metadata, the full
+// message and the syntax-highlighted patch. Cancelling ctx stops git show.
+func (c Commit) GeneratePreview(ctx context.Context, width, height int) string {
+	var sb strings.Builder
+
+	sb.WriteString(ui.LabelStyle.Render("Commit") + "\n")
+	sb.WriteString(ui.ContentStyle.Render(c.Hash) + "\n")
+	if c.Refs != "" {
+		sb.WriteString(ui.InactiveContextStyle.Render(ansi.Truncate(c.Refs, width, "…")) + "\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString(ui.LabelStyle.Render("Author") + "\n")
+	sb.WriteString(ui.ContentStyle.Render(c.Author) + "\n")
+	sb.WriteString(ui.ContentStyle.Render(ui.FormatTime(c.Timestamp)+" ("+ui.FormatRelativeTime(c.Timestamp)+")") + "\n\n")
+
+	out, err := c.show(ctx)
+	if err != nil {
+		sb.WriteString(ui.InactiveContextStyle.Render("  (could not run git show)") + "\n")
+		return sb.String()
+	}
+
+	message, patch, _ := strings.Cut(out, "\x00")
+	// git separates the message from the stat with a "---" line.
+	patch = strings.TrimPrefix(strings.TrimLeft(patch, "\n"), "---\n")
+	sb.WriteString(ui.ContextHeaderStyle.Render("Message") + "\n")
+	for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		sb.WriteString(ui.ContentStyle.Render(ansi.Truncate(line, width, "…")) + "\n")
+	}
+	sb.WriteString("\n")
+
+	if strings.TrimSpace(patch) != "" {
+		sb.WriteString(ui.ContextHeaderStyle.Render("Changes") + "\n")
+		sb.WriteString(ui.GetDiffPreview(patch, ui.MaxDiffLines, width))
+	}
+
+	return sb.String()
+}
+
+// GeneratePreview generates a preview of the stash entry with its patch.
+// Cancelling ctx stops git stash show.
+func (s Stash) GeneratePreview(ctx context.Context, width, height int) string {
+	var sb strings.Builder
+
+	sb.WriteString(ui.LabelStyle.Render("Stash") + "\n")
+	sb.WriteString(ui.ContentStyle.Render(s.Ref) + "\n")
+	sb.WriteString(ui.ContentStyle.Render(ansi.Truncate(s.Message, width, "…")) + "\n\n")
+
+	if s.Branch != "" {
+		sb.WriteString(ui.LabelStyle.Render("Branch") + "\n")
+		sb.WriteString(ui.ContentStyle.Render(s.Branch) + "\n\n")
+	}
+
+	sb.WriteString(ui.LabelStyle.Render("Created") + "\n")
+	sb.WriteString(ui.ContentStyle.Render(ui.FormatTime(s.Timestamp)+" ("+ui.FormatRelativeTime(s.Timestamp)+")") + "\n\n")
+
+	sb.WriteString(ui.ContextHeaderStyle.Render("Changes") + "\n")
+	out, err := s.show(ctx)
+	if err != nil {
+		sb.WriteString(ui.InactiveContextStyle.Render("  (could not run git stash show)") + "\n")
+		return sb.String()
+	}
+	sb.WriteString(ui.GetDiffPreview(out, ui.MaxDiffLines, width))
+
 	return sb.String()
 }
+
+// GeneratePreview generates a preview of the changed file: the staged and
+// unstaged patches, or the file contents when it is untracked. Cancelling ctx
+// stops git diff.
+func (f FileStatus) GeneratePreview(ctx context.Context, width, height int) string {
+	var sb strings.Builder
+
+	sb.WriteString(ui.LabelStyle.Render("Status") + "\n")
+	var state []string
+	switch {
+	case f.IsUntracked():
+		state = append(state, "untracked")
+	default:
+		if f.IsStaged() {
+			state = append(state, "staged")
+		}
+		if f.HasUnstaged() {
+			state = append(state, "unstaged changes")
+		}
+	}
+	line := f.Code() + "  " + strings.Join(state, ", ")
+	if f.OrigPath != "" {
+		line += " (from " + f.OrigPath + ")"
+	}
+	sb.WriteString(ui.ContentStyle.Render(line) + "\n\n")
+
+	if f.IsUntracked() {
+		// Untracked files have no diff; show them like file search does.
+		entry := files.Entry{Path: filepath.Join(f.Root, f.Path)}
+		sb.WriteString(entry.GeneratePreview(width, height))
+		return sb.String()
+	}
+
+	for _, staged := range []bool{true, false} {
+		if staged && !f.IsStaged() || !staged && !f.HasUnstaged() {
+			continue
+		}
+		header := "Unstaged"
+		if staged {
+			header = "Staged"
+		}
+		sb.WriteString(ui.ContextHeaderStyle.Render(header) + "\n")
+		out, err := f.diff(ctx, staged)
+		if err != nil {
+			sb.WriteString(ui.InactiveContextStyle.Render("  (could not run git diff)") + "\n\n")
+			continue
+		}
+		sb.WriteString(ui.GetDiffPreview(out, ui.MaxDiffLines, width))
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// orDefault returns s, or def when s is empty.
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -1,8 +1,14 @@
 package git
 
 import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -17,6 +23,11 @@ type Branch struct {
 	LastCommitMessage string
 	CommitDate        string
 	CommitTimestamp   int64 // Unix timestamp for recency scoring
+	Author            string
+	Upstream          string // short upstream name (local branches only)
+	Ahead             int    // commits ahead of Upstream
+	Behind            int    // commits behind Upstream
+	UpstreamGone      bool   // upstream is configured but no longer exists
 }
 
 // Repository provides git operations for a working directory
@@ -35,8 +46,18 @@ func (r *Repository) IsRepo() bool {
 	return err == nil
 }
 
+// Root returns the top directory of the working tree the path is in.
+func (r *Repository) Root() (string, error) {
+	out, err := r.runGit("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
 // Branches collects all git branches (local and remote)
-// Lightweight version: does not fetch commit objects for performance
+// Lightweight version: does not fetch commit objects for performance.
+// Use LoadDetails to fill in commit metadata afterwards.
 func (r *Repository) Branches() ([]Branch, error) {
 	var branches []Branch
 
@@ -126,6 +147,34 @@ func (r *Repository) Branches() ([]Branch, error) {
 	return branches, nil
 }
 
+// dir returns the absolute path of the repository directory, for git
+// commands run later, when the current directory may have changed.
+func (r *Repository) dir() string {
+	if abs, err := filepath.Abs(r.Path); err == nil {
+		return abs
+	}
+	return r.Path
+}
+
+// runGit runs git in the repository path and returns its output. A failing
+// command's error carries git's own message, so it can be shown to the user.
+func (r *Repository) runGit(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Path
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			// Keep the first line: the rest is usually hints.
+			msg, _, _ = strings.Cut(msg, "\n")
+			return "", fmt.Errorf("%s", strings.TrimPrefix(msg, "fatal: "))
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
 // currentBranch returns the current git branch name using existing repo
 func (r *Repository) currentBranch(repo *gogit.Repository) string {
 	head, err := repo.Head()
@@ -140,3 +189,119 @@ func (r *Repository) currentBranch(repo *gogit.Repository) string {
 
 	return ""
 }
+
+// branchDetails is the commit metadata of a single ref as reported by
+// `git for-each-ref`.
+type branchDetails struct {
+	timestamp    int64
+	author       string
+	subject      string
+	upstream     string
+	ahead        int
+	behind       int
+	upstreamGone bool
+}
+
+// branchDetailsFormat separates fields with NUL so subjects and author names
+// may contain any printable character.
+const branchDetailsFormat = "%(refname)%00%(committerdate:unix)%00%(authorname)%00%(subject)%00%(upstream:short)%00%(upstream:track)"
+
+// LoadDetails returns a copy of branches with commit time, author, subject and
+// upstream tracking information filled in, newest commits first within the
+// local and remote groups.
+//
+// A single `git for-each-ref` call reads every ref at once, which stays fast in
+// repositories with thousands of remote refs where loading each commit object
+// through go-git would not. It is meant to run in the background after
+// Branches has returned, so startup never waits for it.
+func (r *Repository) LoadDetails(branches []Branch) ([]Branch, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format="+branchDetailsFormat, "refs/heads", "refs/remotes")
+	cmd.Dir = r.Path
+	out, err := cmd.Output()
+	if err != nil {
+		return branches, err
+	}
+
+	details := parseBranchDetails(string(out))
+
+	loaded := make([]Branch, len(branches))
+	copy(loaded, branches)
+	for i := range loaded {
+		b := &loaded[i]
+		ref := "refs/heads/" + b.Name
+		if b.IsRemote {
+			ref = "refs/remotes/" + b.Name
+		}
+		d, ok := details[ref]
+		if !ok {
+			continue
+		}
+		b.CommitTimestamp = d.timestamp
+		if d.timestamp > 0 {
+			b.CommitDate = time.Unix(d.timestamp, 0).Format("2006-01-02 15:04:05")
+		}
+		b.Author = d.author
+		b.LastCommitMessage = d.subject
+		b.Upstream = d.upstream
+		b.Ahead = d.ahead
+		b.Behind = d.behind
+		b.UpstreamGone = d.upstreamGone
+	}
+
+	SortByRecency(loaded)
+	return loaded, nil
+}
+
+// SortByRecency orders branches local first, then remote, with the most
+// recently committed branch first in each group. Branches without commit
+// metadata keep their alphabetical order after the dated ones.
+func SortByRecency(branches []Branch) {
+	sort.SliceStable(branches, func(i, j int) bool {
+		a, b := branches[i], branches[j]
+		if a.IsRemote != b.IsRemote {
+			return !a.IsRemote
+		}
+		return a.CommitTimestamp > b.CommitTimestamp
+	})
+}
+
+// parseBranchDetails parses `git for-each-ref` output written with
+// branchDetailsFormat into a map keyed by full ref name.
+func parseBranchDetails(out string) map[string]branchDetails {
+	details := make(map[string]branchDetails)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 6 {
+			continue
+		}
+		ts, _ := strconv.ParseInt(fields[1], 10, 64)
+		d := branchDetails{
+			timestamp: ts,
+			author:    fields[2],
+			subject:   fields[3],
+			upstream:  fields[4],
+		}
+		d.ahead, d.behind, d.upstreamGone = parseTrack(fields[5])
+		details[fields[0]] = d
+	}
+	return details
+}
+
+// parseTrack parses an upstream tracking summary such as "[ahead 1, behind 2]"
+// or "[gone]", as printed by `%(upstream:track)` and `git status --branch`.
+func parseTrack(track string) (ahead, behind int, gone bool) {
+	track = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(track), "["), "]")
+	for _, part := range strings.Split(track, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), " ")
+		n, _ := strconv.Atoi(value)
+		switch key {
+		case "ahead":
+			ahead = n
+		case "behind":
+			behind = n
+		case "gone":
+			gone = true
+		}
+	}
+	return ahead, behind, gone
+}
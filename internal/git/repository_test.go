@@ -2,6 +2,7 @@ package git
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -112,3 +113,111 @@ func TestBranches_InGitRepo(t *testing.T) {
 		t.Errorf("expected at most 1 current branch, got %d", currentCount)
 	}
 }
+
+func TestParseBranchDetails(t *testing.T) {
+	out := "refs/heads/main\x001700000000\x00Alice\x00Fix the parser\x00origin/main\x00[ahead 2, behind 1]\n" +
+		"refs/heads/topic\x001700000100\x00Bob\x00WIP: a, b\x00origin/topic\x00[gone]\n" +
+		"refs/remotes/origin/main\x001690000000\x00Alice\x00Initial commit\x00\x00\n"
+
+	got := parseBranchDetails(out)
+
+	want := map[string]branchDetails{
+		"refs/heads/main":          {timestamp: 1700000000, author: "Alice", subject: "Fix the parser", upstream: "origin/main", ahead: 2, behind: 1},
+		"refs/heads/topic":         {timestamp: 1700000100, author: "Bob", subject: "WIP: a, b", upstream: "origin/topic", upstreamGone: true},
+		"refs/remotes/origin/main": {timestamp: 1690000000, author: "Alice", subject: "Initial commit"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d refs, want %d", len(got), len(want))
+	}
+	for ref, w := range want {
+		if got[ref] != w {
+			t.Errorf("%s = %+v, want %+v", ref, got[ref], w)
+		}
+	}
+}
+
+func TestParseTrack(t *testing.T) {
+	tests := []struct {
+		track        string
+		ahead        int
+		behind       int
+		upstreamGone bool
+	}{
+		{"", 0, 0, false},
+		{"[ahead 3]", 3, 0, false},
+		{"[behind 4]", 0, 4, false},
+		{"[ahead 1, behind 2]", 1, 2, false},
+		{"[gone]", 0, 0, true},
+	}
+	for _, tt := range tests {
+		ahead, behind, gone := parseTrack(tt.track)
+		if ahead != tt.ahead || behind != tt.behind || gone != tt.upstreamGone {
+			t.Errorf("parseTrack(%q) = (%d, %d, %v), want (%d, %d, %v)",
+				tt.track, ahead, behind, gone, tt.ahead, tt.behind, tt.upstreamGone)
+		}
+	}
+}
+
+func TestSortByRecency(t *testing.T) {
+	branches := []Branch{
+		{Name: "a-old", CommitTimestamp: 100},
+		{Name: "b-new", CommitTimestamp: 300},
+		{Name: "origin/new", IsRemote: true, CommitTimestamp: 400},
+		{Name: "c-mid", CommitTimestamp: 200},
+	}
+
+	SortByRecency(branches)
+
+	want := []string{"b-new", "c-mid", "a-old", "origin/new"}
+	for i, name := range want {
+		if branches[i].Name != name {
+			t.Errorf("branches[%d] = %q, want %q", i, branches[i].Name, name)
+		}
+	}
+}
+
+func TestLoadDetails_InGitRepo(t *testing.T) {
+	r := NewRepository(".")
+	if !r.IsRepo() {
+		t.Skip("not running in a git repository")
+	}
+
+	branches, err := r.Branches()
+	if err != nil {
+		t.Fatalf("Branches() returned unexpected error: %v", err)
+	}
+	loaded, err := r.LoadDetails(branches)
+	if err != nil {
+		t.Fatalf("LoadDetails() returned unexpected error: %v", err)
+	}
+	if len(loaded) != len(branches) {
+		t.Fatalf("LoadDetails() returned %d branches, want %d", len(loaded), len(branches))
+	}
+	for _, b := range loaded {
+		if b.CommitTimestamp == 0 {
+			t.Errorf("branch %q has no commit timestamp", b.Name)
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+	dir := initTestRepo(t)
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := NewRepository(sub).Root()
+	if err != nil {
+		t.Fatalf("Root() returned unexpected error: %v", err)
+	}
+	// The temp dir may be reached through a symlink (e.g. /tmp on macOS).
+	want, _ := filepath.EvalSymlinks(dir)
+	if got, _ := filepath.EvalSymlinks(root); got != want {
+		t.Errorf("Root() = %q, want %q", root, dir)
+	}
+
+	if _, err := NewRepository(t.TempDir()).Root(); err == nil {
+		t.Error("Root() outside of a repository returned no error")
+	}
+}
@@ -0,0 +1,90 @@
+package git
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Stash represents a single `git stash` entry
+type Stash struct {
+	Ref       string // e.g. "stash@{0}"
+	Hash      string
+	Branch    string // branch the stash was created on
+	Message   string
+	Timestamp int64
+	Dir       string // repository directory it was listed in, where git stash show runs
+}
+
+// stashFormat separates fields with NUL; %gs is the reflog subject, e.g.
+// "WIP on main: 1a2b3c4 subject" or "On main: message".
+const stashFormat = "%gd%x00%H%x00%ct%x00%gs"
+
+// Stashes lists the stash entries of the repository, newest first. Stashes
+// are shared by every worktree of a repository.
+func (r *Repository) Stashes() ([]Stash, error) {
+	out, err := r.runGit("stash", "list", "--format="+stashFormat)
+	if err != nil {
+		return nil, err
+	}
+	stashes := parseStashList(out)
+	dir := r.dir()
+	for i := range stashes {
+		stashes[i].Dir = dir
+	}
+	return stashes, nil
+}
+
+// parseStashList parses `git stash list` output written with stashFormat.
+func parseStashList(out string) []Stash {
+	var stashes []Stash
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 4 {
+			continue
+		}
+		ts, _ := strconv.ParseInt(fields[2], 10, 64)
+		branch, message := parseStashSubject(fields[3])
+		stashes = append(stashes, Stash{
+			Ref:       fields[0],
+			Hash:      fields[1],
+			Branch:    branch,
+			Message:   message,
+			Timestamp: ts,
+		})
+	}
+	return stashes
+}
+
+// parseStashSubject splits a stash reflog subject into the branch it was
+// created on and its message. `git stash` writes "WIP on <branch>: <hash>
+// <subject>" without a message and "On <branch>: <message>" with one.
+func parseStashSubject(subject string) (branch, message string) {
+	rest := subject
+	switch {
+	case strings.HasPrefix(rest, "WIP on "):
+		rest = strings.TrimPrefix(rest, "WIP on ")
+	case strings.HasPrefix(rest, "On "):
+		rest = strings.TrimPrefix(rest, "On ")
+	default:
+		return "", subject
+	}
+	branch, message, ok := strings.Cut(rest, ": ")
+	if !ok {
+		return "", subject
+	}
+	return branch, message
+}
+
+// show returns the stat and patch of the stash, as printed by
+// `git stash show`.
+func (s Stash) show(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "stash", "show", "--no-color", "--stat", "--patch", s.Ref)
+	cmd.Dir = s.Dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
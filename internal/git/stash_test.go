@@ -0,0 +1,74 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseStashList(t *testing.T) {
+	out := "stash@{0}\x001722aca77a3a5d5ee18730db4e0b60a481796b4e\x001700000100\x00On main: try the new parser\n" +
+		"stash@{1}\x003ff433cdc8a58cdebd365b0d6e2d4cf43b2d5752\x001700000000\x00WIP on feature/x: 767abcd Add flag\n"
+
+	got := parseStashList(out)
+
+	want := []Stash{
+		{Ref: "stash@{0}", Hash: "1722aca77a3a5d5ee18730db4e0b60a481796b4e", Branch: "main", Message: "try the new parser", Timestamp: 1700000100},
+		{Ref: "stash@{1}", Hash: "3ff433cdc8a58cdebd365b0d6e2d4cf43b2d5752", Branch: "feature/x", Message: "767abcd Add flag", Timestamp: 1700000000},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d stashes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("stash %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseStashSubject_Unknown(t *testing.T) {
+	branch, message := parseStashSubject("autostash")
+	if branch != "" || message != "autostash" {
+		t.Errorf("parseStashSubject(%q) = (%q, %q), want (\"\", %q)", "autostash", branch, message, "autostash")
+	}
+}
+
+func TestStashShow_RunsInItsRepository(t *testing.T) {
+	dir := initTestRepo(t)
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("one\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"add", "notes.txt"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "notes"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.WriteFile(file, []byte("two\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "-c", "user.name=test", "-c", "user.email=test@example.com", "stash", "-q")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git stash failed: %v\n%s", err, out)
+	}
+
+	stashes, err := NewRepository(dir).Stashes()
+	if err != nil || len(stashes) != 1 {
+		t.Fatalf("Stashes() = %v, %v; want the stash", stashes, err)
+	}
+	// Previews render after the current directory may have changed.
+	t.Chdir(t.TempDir())
+	out, err := stashes[0].show(context.Background())
+	if err != nil || !strings.Contains(out, "+two") {
+		t.Errorf("show() = %q, %v; want the stashed change", out, err)
+	}
+}
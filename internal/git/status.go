@@ -0,0 +1,156 @@
+package git
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// FileStatus is a changed, staged or untracked file in a worktree, as
+// reported by `git status --porcelain`.
+type FileStatus struct {
+	Root     string // worktree root the paths are relative to
+	Path     string
+	OrigPath string // source path of a rename or copy
+	Index    byte   // staged status (X): ' ', 'M', 'A', 'D', 'R', 'C', 'U' or '?'
+	Worktree byte   // unstaged status (Y): ' ', 'M', 'D', 'U' or '?'
+}
+
+// Status is the state of a worktree: its branch, upstream and changed files
+type Status struct {
+	Root     string
+	Branch   string // empty when HEAD is detached
+	Upstream string
+	Ahead    int
+	Behind   int
+	Files    []FileStatus
+}
+
+// Code returns the two-letter porcelain status, e.g. "M ", " M" or "??".
+func (f FileStatus) Code() string {
+	return string([]byte{f.Index, f.Worktree})
+}
+
+// IsUntracked reports whether the file is not tracked by git.
+func (f FileStatus) IsUntracked() bool {
+	return f.Index == '?'
+}
+
+// IsStaged reports whether the file has changes in the index.
+func (f FileStatus) IsStaged() bool {
+	return f.Index != ' ' && f.Index != '?'
+}
+
+// HasUnstaged reports whether the file has changes not yet in the index.
+func (f FileStatus) HasUnstaged() bool {
+	return f.Worktree != ' '
+}
+
+// Status reports the branch and changed files of the worktree containing the
+// repository path. File paths are relative to Status.Root.
+func (r *Repository) Status() (Status, error) {
+	root, err := r.runGit("rev-parse", "--show-toplevel")
+	if err != nil {
+		return Status{}, err
+	}
+	root = strings.TrimSpace(root)
+
+	// -z keeps paths verbatim: without it, git quotes names containing
+	// spaces or non-ASCII characters.
+	out, err := r.runGit("status", "--porcelain=v1", "-z", "--branch", "--untracked-files=all")
+	if err != nil {
+		return Status{}, err
+	}
+
+	status := parseStatusPorcelain(out)
+	status.Root = root
+	for i := range status.Files {
+		status.Files[i].Root = root
+	}
+	return status, nil
+}
+
+// Stage adds the given files, relative to the worktree root, to the index.
+func (r *Repository) Stage(root string, paths ...string) error {
+	args := append([]string{"-C", root, "add", "--"}, paths...)
+	_, err := r.runGit(args...)
+	return err
+}
+
+// Unstage removes the given files, relative to the worktree root, from the
+// index. `git reset` is used rather than `git restore --staged` because it
+// also works before the first commit.
+func (r *Repository) Unstage(root string, paths ...string) error {
+	args := append([]string{"-C", root, "reset", "--quiet", "--"}, paths...)
+	_, err := r.runGit(args...)
+	return err
+}
+
+// parseStatusPorcelain parses `git status --porcelain=v1 -z --branch`
+// output. Records are NUL-terminated; a rename or copy record is followed by
+// an extra record holding the source path.
+func parseStatusPorcelain(out string) Status {
+	var status Status
+	records := strings.Split(out, "\x00")
+	for i := 0; i < len(records); i++ {
+		rec := records[i]
+		if strings.HasPrefix(rec, "## ") {
+			parseStatusBranch(&status, strings.TrimPrefix(rec, "## "))
+			continue
+		}
+		if len(rec) < 4 {
+			continue
+		}
+		f := FileStatus{
+			Index:    rec[0],
+			Worktree: rec[1],
+			Path:     filepath.FromSlash(rec[3:]),
+		}
+		if (f.Index == 'R' || f.Index == 'C') && i+1 < len(records) {
+			i++
+			f.OrigPath = filepath.FromSlash(records[i])
+		}
+		status.Files = append(status.Files, f)
+	}
+	return status
+}
+
+// parseStatusBranch parses the "## " header of `git status --branch`, e.g.
+// "main...origin/main [ahead 1, behind 2]", "No commits yet on main" or
+// "HEAD (no branch)".
+func parseStatusBranch(status *Status, header string) {
+	switch {
+	case strings.HasPrefix(header, "No commits yet on "):
+		status.Branch = strings.TrimPrefix(header, "No commits yet on ")
+		return
+	case strings.HasPrefix(header, "Initial commit on "):
+		status.Branch = strings.TrimPrefix(header, "Initial commit on ")
+		return
+	case strings.HasPrefix(header, "HEAD (no branch)"):
+		return
+	}
+
+	names, track, _ := strings.Cut(header, " ")
+	branch, upstream, _ := strings.Cut(names, "...")
+	status.Branch = branch
+	status.Upstream = upstream
+	status.Ahead, status.Behind, _ = parseTrack(track)
+}
+
+// diff returns the staged or unstaged patch of the file.
+func (f FileStatus) diff(ctx context.Context, staged bool) (string, error) {
+	args := []string{"-C", f.Root, "diff", "--no-color"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	args = append(args, "--", f.Path)
+	if f.OrigPath != "" {
+		args = append(args, f.OrigPath)
+	}
+	out, err := exec.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,121 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusPorcelain(t *testing.T) {
+	out := "## main...origin/main [ahead 1, behind 2]\x00" +
+		"M  staged.go\x00" +
+		" M unstaged.go\x00" +
+		"RM new name.go\x00old name.go\x00" +
+		"?? dir/untracked.txt\x00"
+
+	got := parseStatusPorcelain(out)
+
+	want := Status{
+		Branch:   "main",
+		Upstream: "origin/main",
+		Ahead:    1,
+		Behind:   2,
+		Files: []FileStatus{
+			{Path: "staged.go", Index: 'M', Worktree: ' '},
+			{Path: "unstaged.go", Index: ' ', Worktree: 'M'},
+			{Path: "new name.go", OrigPath: "old name.go", Index: 'R', Worktree: 'M'},
+			{Path: "dir/untracked.txt", Index: '?', Worktree: '?'},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStatusPorcelain() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseStatusBranch(t *testing.T) {
+	tests := []struct {
+		header   string
+		branch   string
+		upstream string
+	}{
+		{"main", "main", ""},
+		{"main...origin/main", "main", "origin/main"},
+		{"No commits yet on main", "main", ""},
+		{"HEAD (no branch)", "", ""},
+	}
+	for _, tt := range tests {
+		var status Status
+		parseStatusBranch(&status, tt.header)
+		if status.Branch != tt.branch || status.Upstream != tt.upstream {
+			t.Errorf("parseStatusBranch(%q) = (%q, %q), want (%q, %q)",
+				tt.header, status.Branch, status.Upstream, tt.branch, tt.upstream)
+		}
+	}
+}
+
+func TestFileStatus_State(t *testing.T) {
+	tests := []struct {
+		f           FileStatus
+		untracked   bool
+		staged      bool
+		hasUnstaged bool
+	}{
+		{FileStatus{Index: '?', Worktree: '?'}, true, false, true},
+		{FileStatus{Index: 'M', Worktree: ' '}, false, true, false},
+		{FileStatus{Index: ' ', Worktree: 'M'}, false, false, true},
+		{FileStatus{Index: 'A', Worktree: 'M'}, false, true, true},
+	}
+	for _, tt := range tests {
+		if got := tt.f.IsUntracked(); got != tt.untracked {
+			t.Errorf("%q IsUntracked() = %v, want %v", tt.f.Code(), got, tt.untracked)
+		}
+		if got := tt.f.IsStaged(); got != tt.staged {
+			t.Errorf("%q IsStaged() = %v, want %v", tt.f.Code(), got, tt.staged)
+		}
+		if got := tt.f.HasUnstaged(); got != tt.hasUnstaged {
+			t.Errorf("%q HasUnstaged() = %v, want %v", tt.f.Code(), got, tt.hasUnstaged)
+		}
+	}
+}
+
+func TestStatus_StageAndUnstage(t *testing.T) {
+	dir := t.TempDir()
+	if err := exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Skipf("git init failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("hello\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRepository(dir)
+	fileCode := func() string {
+		t.Helper()
+		status, err := r.Status()
+		if err != nil {
+			t.Fatalf("Status() returned unexpected error: %v", err)
+		}
+		if len(status.Files) != 1 {
+			t.Fatalf("Status() listed %d files, want 1", len(status.Files))
+		}
+		return status.Files[0].Code()
+	}
+
+	if got := fileCode(); got != "??" {
+		t.Fatalf("new file status = %q, want %q", got, "??")
+	}
+	if err := r.Stage(dir, "new.txt"); err != nil {
+		t.Fatalf("Stage() returned unexpected error: %v", err)
+	}
+	if got := fileCode(); got != "A " {
+		t.Fatalf("staged file status = %q, want %q", got, "A ")
+	}
+	// Before the first commit there is no HEAD to reset the index to.
+	if err := r.Unstage(dir, "new.txt"); err != nil {
+		t.Fatalf("Unstage() returned unexpected error: %v", err)
+	}
+	if got := fileCode(); got != "??" {
+		t.Fatalf("unstaged file status = %q, want %q", got, "??")
+	}
+}
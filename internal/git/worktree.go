@@ -1,31 +1,58 @@
 package git
 
 import (
-	"os/exec"
+	"errors"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // Worktree represents a git worktree entry
 type Worktree struct {
-	Path      string
-	Branch    string // short branch name, or "(detached)" / "(bare)"
-	Head      string // short commit hash
-	IsCurrent bool   // whether this worktree is the one we are running in
+	Path        string
+	Branch      string // short branch name, or "(detached)" / "(bare)"
+	Head        string // short commit hash
+	IsCurrent   bool   // whether this worktree is the one we are running in
+	Locked      bool   // locked with `git worktree lock`; git refuses to remove it
+	LockReason  string
+	Prunable    bool // its directory is gone; `git worktree prune` removes it
+	PruneReason string
+
+	// Details is loaded in the background by LoadWorktreeDetails; nil until
+	// then.
+	Details *WorktreeDetails
+}
+
+// WorktreeDetails is the state of a worktree shown in its preview
+type WorktreeDetails struct {
+	Status   Status   // branch, upstream, ahead/behind and changed files
+	Commits  []Commit // most recent commits, newest first
+	Modified int64    // Unix time of the last change to the index or a changed file
 }
 
+// RecentWorktreeCommits is how many commits WorktreeDetails holds.
+const RecentWorktreeCommits = 5
+
+// maxDetailWorkers bounds how many worktrees LoadWorktreeDetails inspects at
+// once; each one runs a few git commands.
+const maxDetailWorkers = 8
+
+// DefaultWorktreePathTemplate places new worktrees next to the main worktree,
+// e.g. ~/src/fuzz.fish-feature-x for branch feature/x of ~/src/fuzz.fish.
+const DefaultWorktreePathTemplate = "{root}/../{repo}-{branch}"
+
 // Worktrees lists all worktrees of the repository using
 // `git worktree list --porcelain`. The git binary is invoked with an
 // argument list (no shell), so worktree paths are never interpreted by a shell.
 func (r *Repository) Worktrees() ([]Worktree, error) {
-	cmd := exec.Command("git", "worktree", "list", "--porcelain")
-	cmd.Dir = r.Path
-	out, err := cmd.Output()
+	out, err := r.runGit("worktree", "list", "--porcelain")
 	if err != nil {
 		return nil, err
 	}
 
-	worktrees := parseWorktreePorcelain(string(out))
+	worktrees := parseWorktreePorcelain(out)
 
 	// Mark the worktree containing the current directory as current.
 	if cwd, err := filepath.Abs(r.Path); err == nil {
@@ -46,6 +73,8 @@ func (r *Repository) Worktrees() ([]Worktree, error) {
 //	worktree /path/to/wt
 //	HEAD <full-hash>
 //	branch refs/heads/<name>   (or "detached" / "bare")
+//	locked [<reason>]          (optional)
+//	prunable [<reason>]        (optional)
 func parseWorktreePorcelain(out string) []Worktree {
 	var worktrees []Worktree
 	var cur Worktree
@@ -84,9 +113,166 @@ func parseWorktreePorcelain(out string) []Worktree {
 			cur.Branch = "(detached)"
 		case "bare":
 			cur.Branch = "(bare)"
+		case "locked":
+			cur.Locked = true
+			cur.LockReason = value
+		case "prunable":
+			cur.Prunable = true
+			cur.PruneReason = value
 		}
 	}
 	flush()
 
 	return worktrees
 }
+
+// WorktreePath expands a worktree path template for branch. {root} is the
+// main worktree's path, {repo} its base name and {branch} the branch name
+// with slashes replaced by dashes, so a branch never creates nested
+// directories. Relative results are resolved against the main worktree.
+func WorktreePath(template, root, branch string) string {
+	path := strings.NewReplacer(
+		"{root}", root,
+		"{repo}", filepath.Base(root),
+		"{branch}", strings.ReplaceAll(branch, "/", "-"),
+	).Replace(template)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return filepath.Clean(path)
+}
+
+// AddWorktree creates a worktree for branch at the path given by template
+// (see WorktreePath) and returns that path. For a remote branch, an existing
+// local branch of the same name is checked out, or a new one tracking the
+// remote branch is created.
+func (r *Repository) AddWorktree(template string, branch Branch) (string, error) {
+	worktrees, err := r.Worktrees()
+	if err != nil {
+		return "", err
+	}
+	if len(worktrees) == 0 {
+		return "", errors.New("no main worktree found")
+	}
+	// git lists the main worktree first.
+	root := worktrees[0].Path
+
+	name := branch.Name
+	if !branch.IsRemote {
+		path := WorktreePath(template, root, name)
+		_, err := r.runGit("worktree", "add", "--", path, name)
+		return path, err
+	}
+
+	_, local, ok := strings.Cut(name, "/")
+	if !ok {
+		return "", errors.New("not a remote branch: " + name)
+	}
+	path := WorktreePath(template, root, local)
+	if _, err := r.runGit("rev-parse", "--verify", "--quiet", "refs/heads/"+local); err == nil {
+		_, err := r.runGit("worktree", "add", "--", path, local)
+		return path, err
+	}
+	_, err = r.runGit("worktree", "add", "--track", "-b", local, "--", path, name)
+	return path, err
+}
+
+// RemoveWorktree removes the worktree at path. Without force git refuses to
+// remove a worktree with uncommitted changes. Locked worktrees are never
+// removed.
+func (r *Repository) RemoveWorktree(path string, force bool) error {
+	args := []string{"worktree", "remove"}
+	if force {
+		args = append(args, "--force")
+	}
+	args = append(args, "--", path)
+	_, err := r.runGit(args...)
+	return err
+}
+
+// PruneWorktrees removes the administrative data of worktrees whose
+// directories no longer exist.
+func (r *Repository) PruneWorktrees() error {
+	_, err := r.runGit("worktree", "prune")
+	return err
+}
+
+// Changes returns how many files in the worktree are modified, staged or
+// untracked.
+func (w Worktree) Changes() (int, error) {
+	status, err := NewRepository(w.Path).Status()
+	if err != nil {
+		return 0, err
+	}
+	return len(status.Files), nil
+}
+
+// LoadWorktreeDetails returns a copy of worktrees with Details filled in,
+// inspecting several worktrees concurrently. Worktrees whose details cannot
+// be read, such as prunable or bare ones, are left without.
+func LoadWorktreeDetails(worktrees []Worktree) []Worktree {
+	loaded := make([]Worktree, len(worktrees))
+	copy(loaded, worktrees)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxDetailWorkers)
+	for i := range loaded {
+		if loaded[i].Prunable || loaded[i].Branch == "(bare)" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(w *Worktree) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if details, err := w.loadDetails(); err == nil {
+				w.Details = &details
+			}
+		}(&loaded[i])
+	}
+	wg.Wait()
+	return loaded
+}
+
+// loadDetails reads the status, recent commits and last-modified time of the
+// worktree.
+func (w Worktree) loadDetails() (WorktreeDetails, error) {
+	r := NewRepository(w.Path)
+	status, err := r.Status()
+	if err != nil {
+		return WorktreeDetails{}, err
+	}
+	details := WorktreeDetails{Status: status}
+
+	// A branch without commits has no log; that is not an error here.
+	out, err := r.runGit("log", "--format="+commitFormat, "--max-count="+strconv.Itoa(RecentWorktreeCommits))
+	if err == nil {
+		details.Commits = parseCommitLog(out)
+	}
+
+	// The index changes on checkout, add and commit; changed files cover edits
+	// not staged yet.
+	if index, err := r.runGit("rev-parse", "--git-path", "index"); err == nil {
+		index = strings.TrimSpace(index)
+		if !filepath.IsAbs(index) {
+			index = filepath.Join(w.Path, index)
+		}
+		details.Modified = modTime(index)
+	}
+	for _, f := range status.Files {
+		if t := modTime(filepath.Join(status.Root, f.Path)); t > details.Modified {
+			details.Modified = t
+		}
+	}
+	return details, nil
+}
+
+// modTime returns the Unix modification time of path, or 0 when it cannot be
+// read (e.g. a deleted file).
+func modTime(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().Unix()
+}
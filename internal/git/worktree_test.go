@@ -1,6 +1,10 @@
 package git
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestParseWorktreePorcelain(t *testing.T) {
 	out := "worktree /repo/main\n" +
@@ -13,6 +17,17 @@ func TestParseWorktreePorcelain(t *testing.T) {
 		"\n" +
 		"worktree /repo/bare\n" +
 		"bare\n" +
+		"\n" +
+		"worktree /repo/usb\n" +
+		"HEAD 7a2ca8c612543f4be2e3e0f56a895c123d77c8cd\n" +
+		"branch refs/heads/usb\n" +
+		"locked usb disk\n" +
+		"\n" +
+		"worktree /repo/gone\n" +
+		"HEAD 7a2ca8c612543f4be2e3e0f56a895c123d77c8cd\n" +
+		"branch refs/heads/gone\n" +
+		"locked\n" +
+		"prunable gitdir file points to non-existent location\n" +
 		"\n"
 
 	got := parseWorktreePorcelain(out)
@@ -21,6 +36,9 @@ func TestParseWorktreePorcelain(t *testing.T) {
 		{Path: "/repo/main", Branch: "main", Head: "7a2ca8c"},
 		{Path: "/repo/wt-detached", Branch: "(detached)", Head: "ce5e84b"},
 		{Path: "/repo/bare", Branch: "(bare)", Head: ""},
+		{Path: "/repo/usb", Branch: "usb", Head: "7a2ca8c", Locked: true, LockReason: "usb disk"},
+		{Path: "/repo/gone", Branch: "gone", Head: "7a2ca8c", Locked: true,
+			Prunable: true, PruneReason: "gitdir file points to non-existent location"},
 	}
 
 	if len(got) != len(want) {
@@ -32,3 +50,98 @@ func TestParseWorktreePorcelain(t *testing.T) {
 		}
 	}
 }
+
+func TestWorktreePath(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{DefaultWorktreePathTemplate, "/src/app-feature-x"},
+		{"{root}/.worktrees/{branch}", "/src/app/.worktrees/feature-x"},
+		{"../wt/{repo}/{branch}", "/src/wt/app/feature-x"},
+		{"/tmp/{branch}", "/tmp/feature-x"},
+	}
+	for _, tt := range tests {
+		if got := WorktreePath(tt.template, "/src/app", "feature/x"); got != filepath.FromSlash(tt.want) {
+			t.Errorf("WorktreePath(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestWorktreeLifecycle(t *testing.T) {
+	dir := initTestRepo(t)
+	r := NewRepository(dir)
+	if err := r.CreateBranch("topic", ""); err != nil {
+		t.Fatalf("CreateBranch() returned unexpected error: %v", err)
+	}
+
+	template := filepath.Join(t.TempDir(), "{branch}")
+	path, err := r.AddWorktree(template, Branch{Name: "topic"})
+	if err != nil {
+		t.Fatalf("AddWorktree() returned unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("worktree directory not created: %v", err)
+	}
+
+	wt := Worktree{Path: path}
+	if n, err := wt.Changes(); err != nil || n != 0 {
+		t.Fatalf("Changes() = %d, %v; want 0, nil", n, err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "new.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := wt.Changes(); err != nil || n != 1 {
+		t.Fatalf("Changes() = %d, %v; want 1, nil", n, err)
+	}
+
+	if err := r.RemoveWorktree(path, false); err == nil {
+		t.Error("RemoveWorktree() of a dirty worktree without force returned no error")
+	}
+	if err := r.RemoveWorktree(path, true); err != nil {
+		t.Fatalf("RemoveWorktree() returned unexpected error: %v", err)
+	}
+	worktrees, err := r.Worktrees()
+	if err != nil {
+		t.Fatalf("Worktrees() returned unexpected error: %v", err)
+	}
+	if len(worktrees) != 1 {
+		t.Errorf("got %d worktrees after removal, want 1", len(worktrees))
+	}
+	if err := r.PruneWorktrees(); err != nil {
+		t.Errorf("PruneWorktrees() returned unexpected error: %v", err)
+	}
+}
+
+func TestLoadWorktreeDetails(t *testing.T) {
+	dir := initTestRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	worktrees := []Worktree{
+		{Path: dir, Branch: "main"},
+		{Path: filepath.Join(dir, "missing"), Branch: "gone", Prunable: true},
+	}
+	got := LoadWorktreeDetails(worktrees)
+
+	if worktrees[0].Details != nil {
+		t.Error("LoadWorktreeDetails() modified its argument")
+	}
+	d := got[0].Details
+	if d == nil {
+		t.Fatal("Details not loaded for a valid worktree")
+	}
+	if len(d.Status.Files) != 1 || d.Status.Files[0].Path != "new.txt" {
+		t.Errorf("Status.Files = %+v, want new.txt", d.Status.Files)
+	}
+	if len(d.Commits) != 1 || d.Commits[0].Subject != "initial" {
+		t.Errorf("Commits = %+v, want the initial commit", d.Commits)
+	}
+	if d.Modified == 0 {
+		t.Error("Modified not set")
+	}
+	if got[1].Details != nil {
+		t.Error("Details loaded for a prunable worktree")
+	}
+}
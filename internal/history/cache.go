@@ -0,0 +1,225 @@
+package history
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The cache is binary, as decoding JSON took most of the startup time with
+// large histories. Every string is stored once, in a table entries refer to
+// by index, and numbers are varints (signed ones zig-zag encoded):
+//
+//	magic "fuzzhist", version
+//	meta:    path, size, mod time, dev, inode
+//	         redaction, offset, line
+//	strings: count, then the length and bytes of each
+//	entries: count, then for each: when, cmd line, count,
+//	         the count and indexes of paths, the count and indexes of secrets
+//
+// The command of entry i is string i, so it is not stored again.
+//
+// Decoded strings share the memory of a single copy of the file, so decoding
+// slices it rather than copying. It is all decoded on load: Parse returns
+// every entry, and they are all ranked as soon as the list shows, so decoding
+// them lazily would only move the work to the first keystroke.
+const cacheMagic = "fuzzhist"
+
+var errCacheCorrupt = errors.New("history cache is corrupt")
+
+// encodeCache returns the cache c in the binary format.
+func encodeCache(c cacheFile) []byte {
+	// Commands are unique, as entries are deduplicated, so they take the
+	// first indexes in order; paths and rule names repeat.
+	table := make([]string, len(c.Entries), len(c.Entries)+64)
+	for i, e := range c.Entries {
+		table[i] = e.Cmd
+	}
+	index := make(map[string]uint64)
+	ref := func(s string) uint64 {
+		i, ok := index[s]
+		if !ok {
+			i = uint64(len(table))
+			index[s] = i
+			table = append(table, s)
+		}
+		return i
+	}
+	paths := make([][]uint64, len(c.Entries))
+	secrets := make([][]uint64, len(c.Entries))
+	for i, e := range c.Entries {
+		for _, p := range e.Paths {
+			paths[i] = append(paths[i], ref(p))
+		}
+		for _, s := range e.Secrets {
+			secrets[i] = append(secrets[i], ref(s))
+		}
+	}
+
+	size := len(cacheMagic) + len(c.Meta.Path) + len(c.Redaction) + 64
+	for _, s := range table {
+		size += len(s) + 2
+	}
+	buf := make([]byte, 0, size+len(c.Entries)*16)
+	buf = append(buf, cacheMagic...)
+	buf = binary.AppendUvarint(buf, uint64(c.Version))
+	buf = appendString(buf, c.Meta.Path)
+	buf = binary.AppendVarint(buf, c.Meta.Size)
+	buf = binary.AppendVarint(buf, c.Meta.ModTime)
+	buf = binary.AppendUvarint(buf, c.Meta.Dev)
+	buf = binary.AppendUvarint(buf, c.Meta.Inode)
+	buf = appendString(buf, c.Redaction)
+	buf = binary.AppendVarint(buf, c.Offset)
+	buf = binary.AppendUvarint(buf, uint64(c.Line))
+
+	buf = binary.AppendUvarint(buf, uint64(len(table)))
+	for _, s := range table {
+		buf = appendString(buf, s)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(c.Entries)))
+	for i, e := range c.Entries {
+		buf = binary.AppendVarint(buf, e.When)
+		buf = binary.AppendUvarint(buf, uint64(e.CmdLine))
+		buf = binary.AppendUvarint(buf, uint64(e.Count))
+		buf = appendRefs(buf, paths[i])
+		buf = appendRefs(buf, secrets[i])
+	}
+	return buf
+}
+
+func appendRefs(buf []byte, refs []uint64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(refs)))
+	for _, r := range refs {
+		buf = binary.AppendUvarint(buf, r)
+	}
+	return buf
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// decodeCache decodes a cache encoded by encodeCache. Caches of another
+// version are not decoded, as their layout may differ.
+func decodeCache(data []byte) (cacheFile, error) {
+	var c cacheFile
+	if len(data) < len(cacheMagic) || string(data[:len(cacheMagic)]) != cacheMagic {
+		return c, errCacheCorrupt
+	}
+	d := cacheDecoder{data: string(data), pos: len(cacheMagic)}
+	if c.Version = int(d.uvarint()); d.err == nil && c.Version != cacheVersion {
+		return c, fmt.Errorf("history cache version %d, want %d", c.Version, cacheVersion)
+	}
+	c.Meta.Path = d.string()
+	c.Meta.Size = d.varint()
+	c.Meta.ModTime = d.varint()
+	c.Meta.Dev = d.uvarint()
+	c.Meta.Inode = d.uvarint()
+	c.Redaction = d.string()
+	c.Offset = d.varint()
+	c.Line = int(d.uvarint())
+
+	table := make([]string, d.count())
+	for i := range table {
+		table[i] = d.string()
+	}
+	str := func() string {
+		i := d.uvarint()
+		if i >= uint64(len(table)) {
+			d.fail()
+			return ""
+		}
+		return table[i]
+	}
+
+	// The paths and secrets of all entries share one slice each; merge
+	// copies them before adding any.
+	c.Entries = make([]Entry, d.count())
+	if len(c.Entries) > len(table) {
+		d.fail()
+		c.Entries = nil
+	}
+	var paths, secrets []string
+	for i := range c.Entries {
+		e := &c.Entries[i]
+		e.Cmd = table[i]
+		e.When = d.varint()
+		e.CmdLine = int(d.uvarint())
+		e.Count = int(d.uvarint())
+		if n := d.count(); n > 0 {
+			start := len(paths)
+			for range n {
+				paths = append(paths, str())
+			}
+			e.Paths = paths[start:len(paths):len(paths)]
+		}
+		if n := d.count(); n > 0 {
+			start := len(secrets)
+			for range n {
+				secrets = append(secrets, str())
+			}
+			e.Secrets = secrets[start:len(secrets):len(secrets)]
+		}
+		if d.err != nil {
+			break
+		}
+	}
+	if d.err == nil && d.pos != len(d.data) {
+		d.fail()
+	}
+	return c, d.err
+}
+
+// cacheDecoder reads the values of a cache in order, remembering the first
+// error; values read after it are zero.
+type cacheDecoder struct {
+	data string
+	pos  int
+	err  error
+}
+
+func (d *cacheDecoder) fail() {
+	if d.err == nil {
+		d.err = errCacheCorrupt
+	}
+	d.pos = len(d.data)
+}
+
+func (d *cacheDecoder) uvarint() uint64 {
+	var v uint64
+	for shift := 0; d.pos < len(d.data) && shift < 64; shift += 7 {
+		b := d.data[d.pos]
+		d.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v
+		}
+	}
+	d.fail()
+	return 0
+}
+
+func (d *cacheDecoder) varint() int64 {
+	u := d.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// count reads the number of values that follow, each taking at least a
+// byte, so a corrupt count cannot make the decoder allocate much.
+func (d *cacheDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)-d.pos) {
+		d.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (d *cacheDecoder) string() string {
+	n := d.count()
+	s := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return s
+}
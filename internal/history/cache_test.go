@@ -0,0 +1,215 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testCache(n int) cacheFile {
+	c := cacheFile{
+		Version:   cacheVersion,
+		Meta:      cacheMeta{Path: "/home/me/.local/share/fish/fish_history", Size: 1 << 24, ModTime: 1700000000123456789, Dev: 2049, Inode: 1234567},
+		Redaction: "3f2a",
+		Offset:    1<<24 - 40,
+		Line:      4 * n,
+		Entries:   make([]Entry, n),
+	}
+	for i := range c.Entries {
+		e := Entry{
+			Cmd:     fmt.Sprintf("git commit -m 'change number %d' && git push origin main", i),
+			When:    1700000000 - int64(i),
+			CmdLine: 4*(n-i) - 3,
+			Count:   1 + i%7,
+		}
+		if i%3 == 0 {
+			e.Paths = []string{fmt.Sprintf("/src/project%d", i%20), "~/notes.txt"}
+		}
+		if i%50 == 0 {
+			e.Secrets = []string{"secret-variable"}
+		}
+		c.Entries[i] = e
+	}
+	return c
+}
+
+func TestCache_RoundTrip(t *testing.T) {
+	for _, c := range []cacheFile{{Version: cacheVersion}, testCache(200)} {
+		got, err := decodeCache(encodeCache(c))
+		if err != nil {
+			t.Fatalf("decodeCache() returned unexpected error: %v", err)
+		}
+		if len(c.Entries) == 0 {
+			c.Entries = []Entry{}
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("decodeCache(encodeCache(c)) = %+v, want %+v", got, c)
+		}
+	}
+}
+
+func TestCache_RejectsCorruptData(t *testing.T) {
+	data := encodeCache(testCache(20))
+	for n := 0; n < len(data); n++ {
+		if _, err := decodeCache(data[:n]); err == nil {
+			t.Fatalf("decodeCache() of the first %d of %d bytes returned no error", n, len(data))
+		}
+	}
+	if _, err := decodeCache(append(bytes.Clone(data), 0)); err == nil {
+		t.Error("decodeCache() with trailing data returned no error")
+	}
+	if _, err := decodeCache([]byte(`{"version":7}`)); err == nil {
+		t.Error("decodeCache() of JSON returned no error")
+	}
+
+	old := testCache(1)
+	old.Version = cacheVersion - 1
+	if _, err := decodeCache(encodeCache(old)); err == nil {
+		t.Error("decodeCache() of another version returned no error")
+	}
+}
+
+func TestWriteCache_RemovesJSONCache(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "history-cache.json")
+	if err := os.WriteFile(legacy, []byte(`{"entries":[{"Cmd":"TOKEN=abc"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := &Parser{Path: filepath.Join(dir, "fish_history"), CacheDir: dir}
+	c := testCache(1)
+	c.Redaction = ""
+	p.writeCache(c)
+
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("JSON cache still exists: %v", err)
+	}
+	if cached, ok := p.readCache(); !ok || len(cached.Entries) != 1 {
+		t.Errorf("readCache() = %+v, %v; want the written cache", cached, ok)
+	}
+}
+
+// The benchmarks compare loading the cache of a large history at startup in
+// the binary format with the JSON format used before.
+
+func encodeJSONCache(c cacheFile) []byte {
+	data, _ := json.Marshal(c)
+	return data
+}
+
+func decodeJSONCache(data []byte) (cacheFile, error) {
+	var c cacheFile
+	err := json.Unmarshal(data, &c)
+	return c, err
+}
+
+// benchmarkParseCached measures what Parse does at every startup with a
+// history file of 100,000 commands, cached with encode: read the cache,
+// decode it and parse the last entry of the file.
+func benchmarkParseCached(b *testing.B, encode func(cacheFile) []byte, decode func([]byte) (cacheFile, error)) {
+	entries := testCache(100_000).Entries
+	var history strings.Builder
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		fmt.Fprintf(&history, "- cmd: %s\n  when: %d\n", e.Cmd, e.When)
+		if len(e.Paths) > 0 {
+			history.WriteString("  paths:\n")
+			for _, path := range e.Paths {
+				fmt.Fprintf(&history, "    - %s\n", path)
+			}
+		}
+	}
+	dir := b.TempDir()
+	p := &Parser{Path: filepath.Join(dir, "fish_history"), CacheDir: dir}
+	if err := os.WriteFile(p.Path, []byte(history.String()), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	p.Parse()
+	cached, ok := p.readCache()
+	if !ok {
+		b.Fatal("Parse() did not write the cache")
+	}
+	cachePath := filepath.Join(dir, "bench-cache")
+	if err := os.WriteFile(cachePath, encode(cached), 0o600); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		data, err := os.ReadFile(cachePath)
+		if err != nil {
+			b.Fatal(err)
+		}
+		c, err := decode(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		file, err := os.Open(p.Path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			b.Fatal(err)
+		}
+		got, ok := p.parseFrom(file, p.cacheMeta(info), &c)
+		_ = file.Close()
+		if !ok || len(got) != len(entries) {
+			b.Fatalf("parseFrom() returned %d entries, %v; want %d", len(got), ok, len(entries))
+		}
+	}
+}
+
+func BenchmarkParse_CachedBinary(b *testing.B) {
+	benchmarkParseCached(b, encodeCache, decodeCache)
+}
+
+func BenchmarkParse_CachedJSON(b *testing.B) {
+	benchmarkParseCached(b, encodeJSONCache, decodeJSONCache)
+}
+
+func BenchmarkReadCache_Binary(b *testing.B) {
+	data := encodeCache(testCache(100_000))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := decodeCache(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadCache_JSON(b *testing.B) {
+	data, err := json.Marshal(testCache(100_000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		var c cacheFile
+		if err := json.Unmarshal(data, &c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteCache_Binary(b *testing.B) {
+	c := testCache(100_000)
+	for b.Loop() {
+		encodeCache(c)
+	}
+}
+
+func BenchmarkWriteCache_JSON(b *testing.B) {
+	c := testCache(100_000)
+	for b.Loop() {
+		if _, err := json.Marshal(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
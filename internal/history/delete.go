@@ -0,0 +1,138 @@
+package history
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Delete removes every entry of the commands cmds from the history file, as
+// `history delete --exact --case-sensitive` does, and returns how many
+// entries it removed. The file is locked the way fish locks it while writing
+// and replaced atomically, so neither a running fish nor a crash leaves it
+// half written. The cache is updated with the new contents, so the next Parse
+// does not read the file again. Commands are given as Parse returns them: with
+// a Redactor, every command masked into one of cmds is removed.
+func (p *Parser) Delete(cmds []string) (int, error) {
+	if p.Path == "" {
+		return 0, errors.New("no history file")
+	}
+
+	file, err := lockFile(p.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return 0, err
+	}
+	targets := make(map[string]bool, len(cmds))
+	for _, cmd := range cmds {
+		targets[cmd] = true
+	}
+	out, removed := removeEntries(data, targets, p.Redactor)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p.Path), ".fish_history-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) //nolint:errcheck
+
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		_ = tmp.Close()
+		return 0, err
+	}
+	if _, err := tmp.Write(out); err != nil {
+		_ = tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	// Once renamed, the file may be appended to by fish at any time, so the
+	// cache describes the file as it is written here.
+	tmpInfo, err := os.Stat(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, p.Path); err != nil {
+		return 0, err
+	}
+
+	p.parseFrom(bytes.NewReader(out), p.cacheMeta(tmpInfo), nil)
+	return removed, nil
+}
+
+// lockFile opens the history file and takes the exclusive flock fish takes
+// before changing it. Fish replaces the file by renaming another over it, so
+// the lock only counts when the file is still the one at path once it is
+// held.
+func lockFile(path string) (*os.File, error) {
+	for attempt := 0; attempt < 10; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		locked, err1 := file.Stat()
+		current, err2 := os.Stat(path)
+		if err1 == nil && err2 == nil && os.SameFile(locked, current) {
+			// Closing the file releases the lock.
+			return file, nil
+		}
+		_ = file.Close()
+	}
+	return nil, fmt.Errorf("%s keeps being replaced; try again", path)
+}
+
+// removeEntries returns the history file data without the entries of the
+// commands in targets, and how many entries it left out. Everything else is
+// kept byte for byte. Commands are compared as r masks them.
+func removeEntries(data []byte, targets map[string]bool, r *Redactor) ([]byte, int) {
+	out := make([]byte, 0, len(data))
+	removed := 0
+	skipping := false
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		if end == 0 {
+			end = len(data)
+		}
+		line := data[:end]
+		data = data[end:]
+
+		// An entry runs from its cmd line to the next one.
+		if cmd, ok := strings.CutPrefix(strings.TrimRight(string(line), "\n"), "- cmd: "); ok {
+			masked, _ := r.Redact(unescape(cmd))
+			skipping = targets[masked]
+			if skipping {
+				removed++
+			}
+		}
+		if !skipping {
+			out = append(out, line...)
+		}
+	}
+	return out, removed
+}
@@ -0,0 +1,85 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDelete(t *testing.T) {
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "fish_history")
+	input := "- cmd: git push\n  when: 1000\n" +
+		"- cmd: export TOKEN=secret\n  when: 1100\n  paths:\n    - /tmp\n" +
+		"- cmd: echo 'a\\\\b'\\nls\n  when: 1200\n" +
+		"- cmd: git push\n  when: 1300\n" +
+		"- cmd: Git push\n  when: 1400\n"
+	if err := os.WriteFile(historyPath, []byte(input), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	p := &Parser{Path: historyPath, CacheDir: filepath.Join(dir, "cache")}
+
+	removed, err := p.Delete([]string{"git push", "export TOKEN=secret", "echo 'a\\b'\nls", "not in history"})
+	if err != nil {
+		t.Fatalf("Delete() returned unexpected error: %v", err)
+	}
+	if removed != 4 {
+		t.Errorf("Delete() removed %d entries, want 4", removed)
+	}
+
+	data, err := os.ReadFile(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Matching is exact and case-sensitive.
+	if want := "- cmd: Git push\n  when: 1400\n"; string(data) != want {
+		t.Errorf("history file = %q, want %q", data, want)
+	}
+	info, err := os.Stat(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("history file mode = %v, want 0640", info.Mode().Perm())
+	}
+
+	// The cache was written for the new file, so Parse does not read the
+	// file from the start again.
+	if cached, ok := p.readCache(); !ok || !cached.continuedBy(p.cacheMeta(info)) {
+		t.Errorf("cache = %#v, %v; want one of the new file", cached, ok)
+	}
+	if entries := p.Parse(); len(entries) != 1 || entries[0].Cmd != "Git push" {
+		t.Errorf("Parse() = %#v, want the remaining entry", entries)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".fish_history-*")); len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %q", leftovers)
+	}
+}
+
+func TestDelete_NothingToRemove(t *testing.T) {
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "fish_history")
+	if err := os.WriteFile(historyPath, []byte("- cmd: ls\n  when: 1000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Parser{Path: historyPath, CacheDir: filepath.Join(dir, "cache")}
+	if removed, err := p.Delete([]string{"pwd"}); removed != 0 || err != nil {
+		t.Errorf("Delete() = %d, %v; want 0, nil", removed, err)
+	}
+	after, err := os.Stat(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("Delete() replaced the file without removing anything")
+	}
+
+	if _, err := (&Parser{Path: filepath.Join(dir, "missing")}).Delete([]string{"ls"}); err == nil {
+		t.Error("Delete() of a missing file returned no error")
+	}
+}
@@ -1,13 +1,44 @@
 package history
 
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
 // Entry represents a single command from Fish shell history
 type Entry struct {
-	Cmd     string
-	When    int64
+	Cmd  string
+	When int64
+	// Paths are the paths the command was run with, of every time it was
+	// run, newest first.
 	Paths   []string
 	CmdLine int
 	// Count is how many times the command appears in the history file. Entries
 	// are deduplicated during parsing, so this is the only place the raw
 	// frequency survives.
 	Count int
+	// Secrets are the names of the rules that found secrets in the command,
+	// which are masked in Cmd. Nil when none were found.
+	Secrets []string
+}
+
+// InDir reports whether the command was run with a path inside dir, which
+// must be absolute and clean. Fish records paths as they were typed, so
+// relative ones, whose directory is unknown, never match.
+func (e Entry) InDir(dir string) bool {
+	home, _ := os.UserHomeDir()
+	for _, p := range e.Paths {
+		if rest, ok := strings.CutPrefix(p, "~"); ok && home != "" && (rest == "" || rest[0] == '/') {
+			p = home + rest
+		}
+		if !filepath.IsAbs(p) {
+			continue
+		}
+		p = filepath.Clean(p)
+		if dir == "/" || p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,28 @@
+package history
+
+import "testing"
+
+func TestEntry_InDir(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+
+	tests := []struct {
+		paths []string
+		dir   string
+		want  bool
+	}{
+		{[]string{"/home/user/project"}, "/home/user/project", true},
+		{[]string{"/home/user/project/main.go"}, "/home/user/project", true},
+		{[]string{"/home/user/project2"}, "/home/user/project", false},
+		{[]string{"/home/user"}, "/home/user/project", false},
+		{[]string{"~/project/docs/"}, "/home/user/project", true},
+		{[]string{"project"}, "/home/user/project", false},
+		{[]string{"/etc/hosts", "/home/user/project"}, "/home/user/project", true},
+		{[]string{"/etc/hosts"}, "/", true},
+		{nil, "/home/user/project", false},
+	}
+	for _, tt := range tests {
+		if got := (Entry{Paths: tt.paths}).InDir(tt.dir); got != tt.want {
+			t.Errorf("Entry{Paths: %q}.InDir(%q) = %v, want %v", tt.paths, tt.dir, got, tt.want)
+		}
+	}
+}
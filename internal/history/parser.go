@@ -2,10 +2,10 @@ package history
 
 import (
 	"bufio"
-	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -15,25 +15,36 @@ import (
 type Parser struct {
 	Path     string // history file path
 	CacheDir string // optional cache directory override, mainly for tests
+	// Redactor masks secrets in the commands, in entries and in the cache;
+	// nil leaves them as they are.
+	Redactor *Redactor
 }
 
+// cacheFile holds the parsed history file up to where its last entry starts:
+// fish only appends to the file, so the next Parse goes on from there. The
+// last entry is parsed every time, since fish may not have finished writing
+// it.
 type cacheFile struct {
-	Version int       `json:"version"`
-	Meta    cacheMeta `json:"meta"`
-	Entries []Entry   `json:"entries"`
+	Version   int
+	Meta      cacheMeta
+	Redaction string  // ID of the Redactor the entries were masked with
+	Offset    int64   // Where the last entry starts
+	Line      int     // Lines before Offset
+	Entries   []Entry // The entries before Offset, as Parse returns them
 }
 
 type cacheMeta struct {
-	Path    string `json:"path"`
-	Size    int64  `json:"size"`
-	ModTime int64  `json:"mod_time"`
-	Dev     uint64 `json:"dev"`
-	Inode   uint64 `json:"inode"`
+	Path    string
+	Size    int64
+	ModTime int64
+	Dev     uint64
+	Inode   uint64
 }
 
-// cacheVersion is bumped whenever the parsed representation changes, so caches
-// written by an older binary are discarded instead of reused.
-const cacheVersion = 3
+// cacheVersion is bumped whenever the parsed representation or the cache
+// format (see cacheMagic) changes, so caches written by an older binary are
+// discarded instead of reused.
+const cacheVersion = 8
 
 // NewParser returns a Parser with the default Fish history file path.
 // Fish stores its history under XDG_DATA_HOME, falling back to ~/.local/share.
@@ -51,35 +62,89 @@ func NewParser() *Parser {
 	}
 }
 
-// Parse reads and parses the Fish shell history file
+// Parse reads and parses the Fish shell history file. Only what was appended
+// since the cached parse is read, unless the file was replaced or shrank.
 func (p *Parser) Parse() []Entry {
 	if p.Path == "" {
 		return []Entry{}
 	}
 
-	info, err := os.Stat(p.Path)
+	file, err := os.Open(p.Path)
 	if err != nil {
 		return []Entry{}
 	}
+	defer file.Close() //nolint:errcheck
 
+	info, err := file.Stat()
+	if err != nil {
+		return []Entry{}
+	}
+
+	// Whatever fish appends from now on is left for the next Parse, so the
+	// cache describes the file as it was when opened.
 	meta := p.cacheMeta(info)
-	if entries, ok := p.readCache(meta); ok {
-		if currentInfo, err := os.Stat(p.Path); err == nil && p.cacheMeta(currentInfo) == meta {
+	if cached, ok := p.readCache(); ok && cached.continuedBy(meta) {
+		if entries, ok := p.parseFrom(file, meta, &cached); ok {
 			return entries
 		}
 	}
+	entries, _ := p.parseFrom(file, meta, nil)
+	return entries
+}
 
-	file, err := os.Open(p.Path)
-	if err != nil {
-		return []Entry{}
+// continuedBy reports whether meta describes the cached file, with anything
+// appended to it since.
+func (c cacheFile) continuedBy(meta cacheMeta) bool {
+	old := c.Meta
+	if old.Path != meta.Path || old.Dev != meta.Dev || old.Inode != meta.Inode {
+		return false
 	}
-	defer file.Close() //nolint:errcheck
+	return meta.Size > old.Size || meta.Size == old.Size && meta.ModTime == old.ModTime
+}
 
-	entries := parseReader(file)
-	if currentInfo, err := os.Stat(p.Path); err == nil && p.cacheMeta(currentInfo) == meta {
-		p.writeCache(meta, entries)
+// parseFrom parses r, the history file described by meta, from where cached
+// stopped, or from the start when cached is nil, and returns every entry:
+// those it found merged with the cached ones. The cache is updated when the
+// parse got further. It reports false when r does not continue the cached
+// file, as an entry does not start where the cache stopped.
+func (p *Parser) parseFrom(r io.ReadSeeker, meta cacheMeta, cached *cacheFile) ([]Entry, bool) {
+	var from cacheFile
+	if cached != nil {
+		from = *cached
 	}
-	return entries
+	if _, err := r.Seek(from.Offset, io.SeekStart); err != nil {
+		return []Entry{}, false
+	}
+
+	raw, lastStart, lastLine := scanEntries(io.LimitReader(r, meta.Size-from.Offset), from.Line)
+	if cached != nil && (len(raw) == 0 || raw[0].CmdLine != from.Line+1) {
+		return nil, false
+	}
+	if len(raw) == 0 {
+		return []Entry{}, true
+	}
+
+	entries := from.Entries
+	if done := raw[:len(raw)-1]; len(done) > 0 || cached == nil {
+		entries = mergeNewer(p.finish(done), from.Entries)
+		p.writeCache(cacheFile{
+			Version:   cacheVersion,
+			Meta:      meta,
+			Redaction: p.Redactor.ID(),
+			Offset:    from.Offset + lastStart,
+			Line:      lastLine,
+			Entries:   entries,
+		})
+	}
+	return mergeNewer(p.finish(raw[len(raw)-1:]), entries), true
+}
+
+// finish turns raw entries, oldest first as scanEntries returns them, into
+// entries as Parse returns them.
+func (p *Parser) finish(raw []Entry) []Entry {
+	entries := slices.Clone(raw)
+	slices.Reverse(entries)
+	return redactEntries(dedupe(entries), p.Redactor)
 }
 
 func (p *Parser) cacheMeta(info os.FileInfo) cacheMeta {
@@ -106,33 +171,28 @@ func fileIdentity(info os.FileInfo) (uint64, uint64) {
 	return uint64(stat.Dev), uint64(stat.Ino)
 }
 
-func (p *Parser) readCache(meta cacheMeta) ([]Entry, bool) {
+// readCache returns the cache when it was written by this version with the
+// same redaction rules.
+func (p *Parser) readCache() (cacheFile, bool) {
+	var cached cacheFile
 	path := p.cachePath()
 	if path == "" {
-		return nil, false
+		return cached, false
 	}
 
 	_ = os.Chmod(path, 0o600)
-	file, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, false
-	}
-	defer file.Close() //nolint:errcheck
-
-	var cached cacheFile
-	if err := json.NewDecoder(file).Decode(&cached); err != nil {
-		return nil, false
+		return cached, false
 	}
-	if cached.Version != cacheVersion || cached.Meta != meta {
-		return nil, false
+	cached, err = decodeCache(data)
+	if err != nil || cached.Version != cacheVersion || cached.Redaction != p.Redactor.ID() {
+		return cached, false
 	}
-	if cached.Entries == nil {
-		return []Entry{}, true
-	}
-	return cached.Entries, true
+	return cached, true
 }
 
-func (p *Parser) writeCache(meta cacheMeta, entries []Entry) {
+func (p *Parser) writeCache(cached cacheFile) {
 	path := p.cachePath()
 	if path == "" {
 		return
@@ -158,13 +218,7 @@ func (p *Parser) writeCache(meta cacheMeta, entries []Entry) {
 		return
 	}
 
-	cached := cacheFile{
-		Version: cacheVersion,
-		Meta:    meta,
-		Entries: entries,
-	}
-	enc := json.NewEncoder(tmp)
-	if err := enc.Encode(cached); err != nil {
+	if _, err := tmp.Write(encodeCache(cached)); err != nil {
 		_ = tmp.Close()
 		return
 	}
@@ -177,6 +231,8 @@ func (p *Parser) writeCache(meta cacheMeta, entries []Entry) {
 		return
 	}
 	_ = os.Chmod(path, 0o600)
+	// The JSON cache of earlier versions may hold unmasked secrets.
+	_ = os.Remove(filepath.Join(dir, "history-cache.json"))
 }
 
 func (p *Parser) cachePath() string {
@@ -192,7 +248,13 @@ func (p *Parser) cachePath() string {
 		}
 		cacheDir = filepath.Join(cacheHome, "fuzz.fish")
 	}
-	return filepath.Join(cacheDir, "history-cache.json")
+	// Masked and unmasked entries are cached apart, so the secrets
+	// subcommand, which always masks, does not make sessions that do not
+	// parse the whole file again, nor the other way round.
+	if p.Redactor != nil {
+		return filepath.Join(cacheDir, "history-cache-masked.bin")
+	}
+	return filepath.Join(cacheDir, "history-cache.bin")
 }
 
 // unescape reverses the escaping Fish applies when writing the history file:
@@ -226,59 +288,131 @@ func unescape(s string) string {
 // parseReader parses Fish shell history entries from an io.Reader.
 // This is exported for testing purposes.
 func parseReader(r io.Reader) []Entry {
-	var entries []Entry
+	raw, _, _ := scanEntries(r, 0)
+	// Reverse to show newest first
+	slices.Reverse(raw)
+	return dedupe(raw)
+}
+
+// scanEntries reads the entries in r, whose first line is line line+1 of the
+// history file, oldest first and each counted once. It also returns where in
+// r the last entry starts and how many lines of the file come before it.
+func scanEntries(r io.Reader, line int) (entries []Entry, lastStart int64, lastLine int) {
 	var current *Entry
-	scanner := bufio.NewScanner(r)
-	lineNum := 0
+	reader := bufio.NewReader(r)
+	var offset int64
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineNum++
+	for {
+		chunk, err := reader.ReadString('\n')
+		if chunk == "" {
+			break
+		}
+		start := offset
+		offset += int64(len(chunk))
+		line++
+		text := strings.TrimSuffix(strings.TrimSuffix(chunk, "\n"), "\r")
 
-		if strings.HasPrefix(line, "- cmd: ") {
+		if strings.HasPrefix(text, "- cmd: ") {
 			if current != nil {
 				entries = append(entries, *current)
 			}
 			current = &Entry{
-				Cmd:     unescape(strings.TrimPrefix(line, "- cmd: ")),
-				CmdLine: lineNum,
+				Cmd:     unescape(strings.TrimPrefix(text, "- cmd: ")),
+				CmdLine: line,
+				Count:   1,
 			}
+			lastStart, lastLine = start, line-1
 		} else if current != nil {
-			if strings.HasPrefix(line, "  when: ") {
-				whenStr := strings.TrimPrefix(line, "  when: ")
+			if strings.HasPrefix(text, "  when: ") {
+				whenStr := strings.TrimPrefix(text, "  when: ")
 				when, err := strconv.ParseInt(whenStr, 10, 64)
 				if err == nil {
 					current.When = when
 				}
-			} else if strings.HasPrefix(line, "    - ") {
-				path := unescape(strings.TrimPrefix(line, "    - "))
+			} else if strings.HasPrefix(text, "    - ") {
+				path := unescape(strings.TrimPrefix(text, "    - "))
 				current.Paths = append(current.Paths, path)
 			}
 		}
+		if err != nil {
+			break
+		}
 	}
 
 	if current != nil {
 		entries = append(entries, *current)
 	}
+	return entries, lastStart, lastLine
+}
 
-	// Reverse to show newest first
-	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
-		entries[i], entries[j] = entries[j], entries[i]
-	}
-
-	// Deduplicate commands - keep only the newest occurrence, recording how
-	// often each command was run so frecency scoring still sees the frequency.
+// dedupe keeps only the newest entry of each command in entries, which are
+// newest first, merging the runs of the older ones into it, so frecency
+// scoring still sees how often commands were run.
+func dedupe(entries []Entry) []Entry {
 	at := make(map[string]int, len(entries))
 	deduplicated := make([]Entry, 0, len(entries))
+	paths := make(pathSets)
 	for _, entry := range entries {
 		if i, ok := at[entry.Cmd]; ok {
-			deduplicated[i].Count++
+			deduplicated[i].merge(entry, paths.of(i, &deduplicated[i]))
 			continue
 		}
-		entry.Count = 1
 		at[entry.Cmd] = len(deduplicated)
 		deduplicated = append(deduplicated, entry)
 	}
-
 	return deduplicated
 }
+
+// mergeNewer returns newer followed by older, both deduplicated and newest
+// first, with the entries of older whose commands are in newer merged into
+// those.
+func mergeNewer(newer, older []Entry) []Entry {
+	at := make(map[string]int, len(newer))
+	merged := make([]Entry, 0, len(newer)+len(older))
+	for _, entry := range newer {
+		at[entry.Cmd] = len(merged)
+		merged = append(merged, entry)
+	}
+	paths := make(pathSets)
+	for _, entry := range older {
+		if i, ok := at[entry.Cmd]; ok {
+			merged[i].merge(entry, paths.of(i, &merged[i]))
+			continue
+		}
+		merged = append(merged, entry)
+	}
+	return merged
+}
+
+// pathSets holds the paths of entries being merged into, by their index, so
+// merging the many runs of a frequent command does not search its paths over
+// and over.
+type pathSets map[int]map[string]bool
+
+// of returns the set of the paths of e, entry i, made on first use.
+func (s pathSets) of(i int, e *Entry) map[string]bool {
+	set, ok := s[i]
+	if !ok {
+		set = make(map[string]bool, len(e.Paths))
+		for _, path := range e.Paths {
+			set[path] = true
+		}
+		s[i] = set
+	}
+	return set
+}
+
+// merge adds the runs of other, an older entry of the same command, to e.
+// paths is the set of e's paths, which it keeps up to date.
+func (e *Entry) merge(other Entry, paths map[string]bool) {
+	e.Count += other.Count
+	// The paths of every run tell where the command is used. They may be
+	// shared with a cached entry, so they are copied before growing.
+	e.Paths = slices.Clip(e.Paths)
+	for _, path := range other.Paths {
+		if !paths[path] {
+			paths[path] = true
+			e.Paths = append(e.Paths, path)
+		}
+	}
+}
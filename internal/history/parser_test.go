@@ -1,9 +1,10 @@
 package history
 
 import (
-	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -37,40 +38,34 @@ func TestParse_UsesCacheWhenMetadataMatches(t *testing.T) {
 	dir := t.TempDir()
 	historyPath := dir + "/fish_history"
 	cacheDir := dir + "/cache"
-	input := "- cmd: original\n  when: 1000\n"
+	input := "- cmd: older\n  when: 900\n- cmd: original\n  when: 1000\n"
 	if err := os.WriteFile(historyPath, []byte(input), 0o600); err != nil {
 		t.Fatal(err)
 	}
 
 	p := &Parser{Path: historyPath, CacheDir: cacheDir}
-	if entries := p.Parse(); len(entries) != 1 || entries[0].Cmd != "original" {
-		t.Fatalf("initial Parse() = %#v, want original entry", entries)
+	if entries := p.Parse(); len(entries) != 2 || entries[0].Cmd != "original" {
+		t.Fatalf("initial Parse() = %#v, want original entry first", entries)
 	}
 
 	info, err := os.Stat(historyPath)
 	if err != nil {
 		t.Fatal(err)
 	}
+	// The entries before the last one come from the cache; the last one is
+	// read from the file.
 	fakeCache := cacheFile{
 		Version: cacheVersion,
 		Meta:    p.cacheMeta(info),
+		Offset:  int64(strings.Index(input, "- cmd: original")),
+		Line:    2,
 		Entries: []Entry{{Cmd: "from cache", When: 2000}},
 	}
-	file, err := os.Create(p.cachePath())
-	if err != nil {
-		t.Fatal(err)
-	}
-	if err := json.NewEncoder(file).Encode(fakeCache); err != nil {
-		_ = file.Close()
-		t.Fatal(err)
-	}
-	if err := file.Close(); err != nil {
-		t.Fatal(err)
-	}
+	p.writeCache(fakeCache)
 
 	entries := p.Parse()
-	if len(entries) != 1 || entries[0].Cmd != "from cache" {
-		t.Fatalf("Parse() = %#v, want cached entry", entries)
+	if len(entries) != 2 || entries[0].Cmd != "original" || entries[0].CmdLine != 3 || entries[1].Cmd != "from cache" {
+		t.Fatalf("Parse() = %#v, want the last entry and the cached one", entries)
 	}
 }
 
@@ -134,7 +129,7 @@ func TestCachePath_UsesXDGCacheHome(t *testing.T) {
 	t.Setenv("XDG_CACHE_HOME", cacheHome)
 
 	p := &Parser{Path: "/tmp/fish_history"}
-	want := filepath.Join(cacheHome, "fuzz.fish", "history-cache.json")
+	want := filepath.Join(cacheHome, "fuzz.fish", "history-cache.bin")
 	if got := p.cachePath(); got != want {
 		t.Fatalf("cachePath() = %q, want %q", got, want)
 	}
@@ -336,6 +331,19 @@ func TestParseReader_CountsRepeatedCommands(t *testing.T) {
 	}
 }
 
+func TestParseReader_MergesPathsOfRepeatedCommands(t *testing.T) {
+	input := "- cmd: make\n  when: 1000\n  paths:\n    - /src/a\n" +
+		"- cmd: make\n  when: 2000\n  paths:\n    - /src/b\n    - /src/a\n"
+
+	entries := parseReader(strings.NewReader(input))
+	if len(entries) != 1 {
+		t.Fatalf("parseReader() returned %d entries, want 1", len(entries))
+	}
+	if got, want := entries[0].Paths, []string{"/src/b", "/src/a"}; !slices.Equal(got, want) {
+		t.Errorf("Paths = %q, want %q", got, want)
+	}
+}
+
 func TestNewParser_HonoursXDGDataHome(t *testing.T) {
 	dir := t.TempDir()
 	t.Setenv("XDG_DATA_HOME", dir)
@@ -358,3 +366,100 @@ func TestNewParser_FallsBackToHome(t *testing.T) {
 		t.Errorf("NewParser().Path = %q, want %q", got, want)
 	}
 }
+
+func appendHistory(t *testing.T, path, data string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(data); err != nil {
+		_ = file.Close()
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParse_ReadsOnlyAppendedEntries(t *testing.T) {
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "fish_history")
+	first := "- cmd: make\n  when: 1000\n  paths:\n    - /src/a\n" +
+		"- cmd: ls\n  when: 1100\n" +
+		"- cmd: make\n  when: 1200\n  paths:\n    - /src/b\n" +
+		// fish has not written the time yet
+		"- cmd: git status\n"
+	if err := os.WriteFile(historyPath, []byte(first), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := &Parser{Path: historyPath, CacheDir: filepath.Join(dir, "cache")}
+	p.Parse()
+
+	// Changing what was parsed in place goes unnoticed, which shows that it
+	// is not read again.
+	file, err := os.OpenFile(historyPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt([]byte("XX"), int64(strings.Index(first, "ls"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rest := "  when: 1300\n" +
+		"- cmd: make\n  when: 1400\n  paths:\n    - /src/c\n" +
+		"- cmd: pwd\n  when: 1500\n"
+	appendHistory(t, historyPath, rest)
+
+	got := p.Parse()
+	want := parseReader(strings.NewReader(first + rest))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() after appending = %#v\nwant %#v", got, want)
+	}
+	if got[1].Cmd != "make" || got[1].Count != 3 || !slices.Equal(got[1].Paths, []string{"/src/c", "/src/b", "/src/a"}) {
+		t.Errorf("make = %#v, want its runs merged", got[1])
+	}
+	if got[2].Cmd != "git status" || got[2].When != 1300 {
+		t.Errorf("git status = %#v, want the time written after the first Parse", got[2])
+	}
+
+	// Nothing appended: the cache is used as it is.
+	if again := p.Parse(); !reflect.DeepEqual(again, got) {
+		t.Errorf("second Parse() = %#v, want %#v", again, got)
+	}
+}
+
+func TestParse_ReparsesShrunkOrReplacedFile(t *testing.T) {
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "fish_history")
+	input := "- cmd: ls\n  when: 1000\n- cmd: pwd\n  when: 1100\n- cmd: id\n  when: 1200\n"
+	if err := os.WriteFile(historyPath, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := &Parser{Path: historyPath, CacheDir: filepath.Join(dir, "cache")}
+	p.Parse()
+
+	// What fish does on `history delete`: the file is written anew and
+	// renamed over the old one.
+	replaced := "- cmd: cd\n  when: 900\n- cmd: ls\n  when: 1000\n- cmd: pwd\n  when: 1100\n- cmd: id\n  when: 1200\n- cmd: w\n  when: 1300\n"
+	tmp := filepath.Join(dir, "fish_history.tmp")
+	if err := os.WriteFile(tmp, []byte(replaced), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, historyPath); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Parse(), parseReader(strings.NewReader(replaced)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() of a replaced file = %#v, want %#v", got, want)
+	}
+
+	shrunk := "- cmd: cd\n  when: 900\n"
+	if err := os.Truncate(historyPath, int64(len(shrunk))); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Parse(), parseReader(strings.NewReader(shrunk)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() of a shrunk file = %#v, want %#v", got, want)
+	}
+}
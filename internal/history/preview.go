@@ -1,7 +1,6 @@
 package history
 
 import (
-	"os"
 	"strings"
 
 	"charm.land/lipgloss/v2"
@@ -9,15 +8,6 @@ import (
 	"github.com/jedipunkz/fuzz.fish/internal/ui"
 )
 
-// formatDir abbreviates a directory path by replacing the home directory with ~
-func formatDir(path string) string {
-	home, err := os.UserHomeDir()
-	if err == nil {
-		path = strings.Replace(path, home, "~", 1)
-	}
-	return path
-}
-
 // GeneratePreview generates a preview of the history entry for the TUI preview window
 func (e Entry) GeneratePreview(all []Entry, idx, width, height int) string {
 	var sb strings.Builder
@@ -33,11 +23,18 @@ func (e Entry) GeneratePreview(all []Entry, idx, width, height int) string {
 	// Dir
 	if len(e.Paths) > 0 {
 		sb.WriteString(ui.LabelStyle.Render("Directory") + "\n")
-		sb.WriteString(ui.ContentStyle.Render(formatDir(e.Paths[0])))
+		sb.WriteString(ui.ContentStyle.Render(ui.FormatDir(e.Paths[0])))
 		sb.WriteString("\n")
 	}
 	sb.WriteString("\n")
 
+	// Secrets
+	if len(e.Secrets) > 0 {
+		sb.WriteString(ui.LabelStyle.Render("Secrets") + "\n")
+		sb.WriteString(ui.ActiveContextStyle.Render("Masked: " + strings.Join(e.Secrets, ", ")))
+		sb.WriteString("\n\n")
+	}
+
 	// Context (commands before/after)
 	sb.WriteString(ui.ContextHeaderStyle.Render("Context") + "\n")
 	start := idx - ui.HistoryContextLinesBefore
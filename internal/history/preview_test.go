@@ -31,3 +31,15 @@ func TestGeneratePreview_MultibyteContext(t *testing.T) {
 		t.Errorf("GeneratePreview() split a multibyte character: %q", got)
 	}
 }
+
+func TestGeneratePreview_NamesSecrets(t *testing.T) {
+	all := []Entry{{Cmd: "curl -u admin:**** example.com", When: 1000, Secrets: []string{"password-option"}}}
+
+	if got := all[0].GeneratePreview(all, 0, 40, 10); !strings.Contains(got, "Masked: password-option") {
+		t.Errorf("GeneratePreview() = %q, want the rules that found secrets", got)
+	}
+	all[0].Secrets = nil
+	if got := all[0].GeneratePreview(all, 0, 40, 10); strings.Contains(got, "Secrets") {
+		t.Errorf("GeneratePreview() = %q, want no secrets section", got)
+	}
+}
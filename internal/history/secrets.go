@@ -0,0 +1,207 @@
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Mask replaces the secrets found in commands
+const Mask = "****"
+
+// Rule detects a kind of secret in commands. The text of the pattern's first
+// group is masked, or the whole match when it has no group.
+type Rule struct {
+	Name    string `toml:"name"`
+	Pattern string `toml:"pattern"` // Go regular expression
+	// Keywords spare running the pattern on most commands: it only runs on
+	// those containing one of them, ignoring case. Empty runs it on all.
+	Keywords []string `toml:"keywords"`
+}
+
+// secretWords are in the names of variables and options holding secrets
+var secretWords = []string{"secret", "token", "password", "passwd", "apikey", "api_key", "api-key", "access_key"}
+
+// DefaultRules detect common credentials typed on the command line.
+var DefaultRules = []Rule{
+	{Name: "authorization-header", Pattern: `(?i)authorization:\s*(?:bearer|basic|token)\s+([^\s'"]+)`, Keywords: []string{"authorization"}},
+	{Name: "secret-variable", Pattern: `(?i)(?:^|[\s;(])[a-z0-9_]*(?:secret|token|password|passwd|api_?key|access_key)[a-z0-9_]*=['"]?([^\s'"]+)`, Keywords: secretWords},
+	{Name: "secret-fish-variable", Pattern: `(?i)\bset\s+(?:-[a-z]+\s+)*[a-z0-9_]*(?:secret|token|password|passwd|api_?key|access_key)[a-z0-9_]*\s+['"]?([^\s'"-][^\s'"]*)`, Keywords: secretWords},
+	{Name: "password-option", Pattern: `(?i)\s--?(?:password|passwd|token|api-key|secret)(?:=|\s+)['"]?([^\s'"-][^\s'"]*)`, Keywords: secretWords},
+	{Name: "mysql-password", Pattern: `\b(?:mysql|mysqldump|mysqladmin|mariadb)\b.*\s-p([^\s'"]+)`, Keywords: []string{"mysql", "mariadb"}},
+	{Name: "url-credentials", Pattern: `\b[a-z][a-z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`, Keywords: []string{"://"}},
+	{Name: "aws-access-key-id", Pattern: `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`, Keywords: []string{"akia", "asia"}},
+	{Name: "github-token", Pattern: `\b((?:gh[pousr]|github_pat)_[A-Za-z0-9_]{36,})\b`, Keywords: []string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_"}},
+	{Name: "slack-token", Pattern: `\b(xox[abposr]-[A-Za-z0-9-]{10,})\b`, Keywords: []string{"xox"}},
+	{Name: "private-key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----[^-]*`, Keywords: []string{"private key"}},
+}
+
+// Redactor masks the secrets its rules detect in commands. A nil Redactor
+// masks nothing.
+type Redactor struct {
+	rules []compiledRule
+	id    string
+}
+
+type compiledRule struct {
+	name     string
+	re       *regexp.Regexp
+	keywords []string // lower case
+}
+
+// NewRedactor compiles rules into a Redactor.
+func NewRedactor(rules []Rule) (*Redactor, error) {
+	r := &Redactor{rules: make([]compiledRule, 0, len(rules))}
+	h := sha256.New()
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		keywords := make([]string, len(rule.Keywords))
+		for i, k := range rule.Keywords {
+			keywords[i] = strings.ToLower(k)
+		}
+		r.rules = append(r.rules, compiledRule{name: rule.Name, re: re, keywords: keywords})
+		_, _ = fmt.Fprintf(h, "%s\x00%s\x00%q\x00", rule.Name, rule.Pattern, keywords)
+	}
+	r.id = hex.EncodeToString(h.Sum(nil))
+	return r, nil
+}
+
+// ID identifies the rules, so entries masked with other rules are told apart
+// (e.g. in the cache). It is empty for a nil Redactor.
+func (r *Redactor) ID() string {
+	if r == nil {
+		return ""
+	}
+	return r.id
+}
+
+// Redact returns cmd with its secrets masked and the names of the rules that
+// found them, which are nil when it has none.
+func (r *Redactor) Redact(cmd string) (string, []string) {
+	if r == nil {
+		return cmd, nil
+	}
+
+	// Rules look at the command as it was typed; the spans they find are
+	// masked together afterwards, so overlapping finds are masked once.
+	var spans [][2]int
+	var names []string
+	lower := strings.ToLower(cmd)
+	for _, rule := range r.rules {
+		if len(rule.keywords) > 0 && !slices.ContainsFunc(rule.keywords, func(k string) bool { return strings.Contains(lower, k) }) {
+			continue
+		}
+		found := false
+		for _, loc := range rule.re.FindAllStringSubmatchIndex(cmd, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			if start < end {
+				spans = append(spans, [2]int{start, end})
+				found = true
+			}
+		}
+		if found {
+			names = append(names, rule.name)
+		}
+	}
+	if len(spans) == 0 {
+		return cmd, nil
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	masked := make([]byte, 0, len(cmd))
+	at := 0
+	for _, span := range spans {
+		if span[0] < at {
+			// Overlaps the span masked before
+			at = max(at, span[1])
+			continue
+		}
+		masked = append(masked, cmd[at:span[0]]...)
+		masked = append(masked, Mask...)
+		at = span[1]
+	}
+	masked = append(masked, cmd[at:]...)
+	return string(masked), names
+}
+
+// redactEntries masks the secrets in entries, which are deduplicated newest
+// first, and merges the commands that are the same once masked (e.g. a login
+// run with different passwords) into the newest of them.
+func redactEntries(entries []Entry, r *Redactor) []Entry {
+	if r == nil {
+		return entries
+	}
+
+	for i := range entries {
+		entries[i].Cmd, entries[i].Secrets = r.Redact(entries[i].Cmd)
+	}
+	return dedupe(entries)
+}
+
+// Reveal returns how each of the commands cmds, as Parse returns them, was
+// typed: the commands in the history file that the Redactor masks into it,
+// newest first. A masked command stands for every command typed with other
+// secrets in its place, so it can reveal several. Commands without secrets,
+// and those no longer in the file, are returned as they are.
+func (p *Parser) Reveal(cmds []string) ([][]string, error) {
+	revealed := make([][]string, len(cmds))
+	for i, cmd := range cmds {
+		revealed[i] = []string{cmd}
+	}
+	if p.Redactor == nil || !slices.ContainsFunc(cmds, func(cmd string) bool { return strings.Contains(cmd, Mask) }) {
+		return revealed, nil
+	}
+
+	file, err := os.Open(p.Path)
+	if err != nil {
+		return revealed, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	wanted := make(map[string]bool, len(cmds))
+	for _, cmd := range cmds {
+		wanted[cmd] = true
+	}
+	// The file is oldest first, so every time a command is typed again it
+	// moves to the end.
+	typed := make(map[string][]string, len(cmds))
+	// Lines are read whole, however long, as scanEntries reads them.
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		text := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if cmd, ok := strings.CutPrefix(text, "- cmd: "); ok {
+			cmd = unescape(cmd)
+			if masked, _ := p.Redactor.Redact(cmd); masked != cmd && wanted[masked] {
+				typed[masked] = append(slices.DeleteFunc(typed[masked], func(t string) bool { return t == cmd }), cmd)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return revealed, err
+		}
+	}
+
+	for i, cmd := range cmds {
+		if t, ok := typed[cmd]; ok {
+			slices.Reverse(t)
+			revealed[i] = t
+		}
+	}
+	return revealed, nil
+}
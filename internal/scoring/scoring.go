@@ -9,35 +9,38 @@ import (
 // Config holds configuration for the unified scoring algorithm
 type Config struct {
 	// Word boundary bonus (match after /, -, _, ., space)
-	WordBoundaryBonus float64
+	WordBoundaryBonus float64 `toml:"word_boundary_bonus"`
 	// Consecutive match bonus
-	ConsecutiveBonus float64
+	ConsecutiveBonus float64 `toml:"consecutive_bonus"`
 	// Prefix match bonus (match at start)
-	PrefixBonus float64
+	PrefixBonus float64 `toml:"prefix_bonus"`
 	// CamelCase bonus (match at uppercase letter)
-	CamelCaseBonus float64
+	CamelCaseBonus float64 `toml:"camel_case_bonus"`
 	// GapStartPenalty is the penalty applied once for each gap (a run of
 	// unmatched characters) between two matched characters.
-	GapStartPenalty float64
+	GapStartPenalty float64 `toml:"gap_start_penalty"`
 	// GapExtensionPenalty is the additional penalty for each unmatched
 	// character within a gap. Larger gaps are penalized more, so a query
 	// whose characters match contiguously ranks above one whose matches are
 	// scattered far apart (fzf/fzy-style gap penalty).
-	GapExtensionPenalty float64
+	GapExtensionPenalty float64 `toml:"gap_extension_penalty"`
 	// MaxGapChars caps the unmatched-character count used for gap extension,
 	// preventing pathologically long lines from dominating the score.
-	MaxGapChars int
+	MaxGapChars int `toml:"max_gap_chars"`
 	// MatchWeight is the multiplier applied to match quality score.
 	// Higher values make match quality the dominant ranking factor.
-	MatchWeight float64
+	MatchWeight float64 `toml:"match_weight"`
 	// FrecencyWeight is the multiplier for frecency bonus (frequency × time decay).
 	// Used in history mode. Frecency score = log1p(frequency) × timeMultiplier × FrecencyWeight.
-	FrecencyWeight float64
+	FrecencyWeight float64 `toml:"frecency_weight"`
 	// MaxRecencyBonus is the max recency bonus for non-history modes (e.g. git branches).
 	// Uses hyperbolic decay: bonus = MaxRecencyBonus / (1 + ageInHours).
-	MaxRecencyBonus float64
+	MaxRecencyBonus float64 `toml:"max_recency_bonus"`
 	// Current branch bonus (git mode only)
-	CurrentBranchBonus float64
+	CurrentBranchBonus float64 `toml:"current_branch_bonus"`
+	// DirectoryBonus is added to history commands previously run with a
+	// path in the current directory, so they rank higher there.
+	DirectoryBonus float64 `toml:"directory_bonus"`
 }
 
 // DefaultConfig returns the default scoring configuration.
@@ -56,6 +59,7 @@ func DefaultConfig() Config {
 		FrecencyWeight:      50.0,  // log1p(freq) × multiplier × 50
 		MaxRecencyBonus:     200.0, // For git branches (was 3000, reduced to same scale)
 		CurrentBranchBonus:  500.0,
+		DirectoryBonus:      300.0, // Between the frecency of a command run today and one run this hour
 	}
 }
 
@@ -191,8 +195,10 @@ func (c Config) FrecencyBonus(timestamp int64, frequency int, now int64) float64
 //	score = (fuzzyScore + matchBonus) × MatchWeight + RecencyBonus
 //
 // This ensures match quality is the primary ranking factor (~10×),
-// with frecency/recency as a secondary signal.
-func (c Config) ItemScore(text string, fuzzyScore int, matchedIndexes []int, timestamp int64, frequency int, isCurrent bool, now int64) float64 {
+// with frecency/recency as a secondary signal. CurrentBranchBonus is added for
+// the current branch and DirectoryBonus for history run in the current
+// directory (inDir).
+func (c Config) ItemScore(text string, fuzzyScore int, matchedIndexes []int, timestamp int64, frequency int, isCurrent, inDir bool, now int64) float64 {
 	// Match quality is the primary signal, amplified by MatchWeight
 	matchScore := (float64(fuzzyScore) + c.MatchBonus(text, matchedIndexes)) * c.MatchWeight
 
@@ -208,6 +214,9 @@ func (c Config) ItemScore(text string, fuzzyScore int, matchedIndexes []int, tim
 	if isCurrent {
 		score += c.CurrentBranchBonus
 	}
+	if inDir {
+		score += c.DirectoryBonus
+	}
 
 	return score
 }
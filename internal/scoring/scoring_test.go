@@ -193,7 +193,7 @@ func TestItemScoreHistory(t *testing.T) {
 	now := int64(1000000)
 
 	// History mode: frequency > 0 triggers frecency path
-	score := config.ItemScore("git commit -m 'test'", 100, []int{0, 1, 2}, now-3600, 5, false, now)
+	score := config.ItemScore("git commit -m 'test'", 100, []int{0, 1, 2}, now-3600, 5, false, false, now)
 
 	// matchScore = (100 + PrefixBonus + ConsecutiveBonus*2) * MatchWeight
 	matchQuality := (100.0 + config.PrefixBonus + config.ConsecutiveBonus*2) * config.MatchWeight
@@ -213,9 +213,9 @@ func TestItemScoreMatchQualityDominates(t *testing.T) {
 	now := int64(1000000)
 
 	// Poor match, very recent and frequent
-	poorMatchScore := config.ItemScore("git stash pop", 10, []int{0}, now-60, 100, false, now)
+	poorMatchScore := config.ItemScore("git stash pop", 10, []int{0}, now-60, 100, false, false, now)
 	// Good match, older and rare
-	goodMatchScore := config.ItemScore("git commit", 100, []int{0, 1, 2}, now-86400*3, 2, false, now)
+	goodMatchScore := config.ItemScore("git commit", 100, []int{0, 1, 2}, now-86400*3, 2, false, false, now)
 
 	if goodMatchScore <= poorMatchScore {
 		t.Errorf("good match score (%v) should > poor match score (%v) — match quality should dominate", goodMatchScore, poorMatchScore)
@@ -227,8 +227,8 @@ func TestItemScoreGitBranch(t *testing.T) {
 	now := int64(1000000)
 
 	// Git mode: frequency=0 triggers RecencyBonus path
-	currentScore := config.ItemScore("main", 100, []int{0}, now-3600, 0, true, now)
-	otherScore := config.ItemScore("feature/test", 100, []int{0}, now-3600, 0, false, now)
+	currentScore := config.ItemScore("main", 100, []int{0}, now-3600, 0, true, false, now)
+	otherScore := config.ItemScore("feature/test", 100, []int{0}, now-3600, 0, false, false, now)
 
 	if currentScore <= otherScore {
 		t.Errorf("Current branch score (%v) should be > other branch score (%v)", currentScore, otherScore)
@@ -245,7 +245,7 @@ func TestItemScoreFiles(t *testing.T) {
 	now := int64(1000000)
 
 	// Files mode: frequency=0, timestamp=0 → only match quality
-	score := config.ItemScore("src/components/Button.tsx", 100, []int{4, 5, 6}, 0, 0, false, now)
+	score := config.ItemScore("src/components/Button.tsx", 100, []int{4, 5, 6}, 0, 0, false, false, now)
 
 	// matchScore = (100 + WordBoundaryBonus + ConsecutiveBonus*2) * MatchWeight
 	minExpected := (100.0 + config.WordBoundaryBonus + config.ConsecutiveBonus*2) * config.MatchWeight
@@ -304,8 +304,8 @@ func TestItemScoreRecentTightMatchBeatsOldScattered(t *testing.T) {
 	now := CurrentTimestamp()
 	// Recently run "git pull origin main" must outrank an older, scattered
 	// "git config pull.rebase true" for the query "git pull".
-	recent := config.ItemScore("git pull origin main", 67, []int{0, 1, 2, 4, 5, 6, 7}, now-60, 1, false, now)
-	old := config.ItemScore("git config pull.rebase true", 58, []int{0, 1, 11, 12, 13, 14, 23}, now-10*24*3600, 1, false, now)
+	recent := config.ItemScore("git pull origin main", 67, []int{0, 1, 2, 4, 5, 6, 7}, now-60, 1, false, false, now)
+	old := config.ItemScore("git config pull.rebase true", 58, []int{0, 1, 11, 12, 13, 14, 23}, now-10*24*3600, 1, false, false, now)
 	if recent <= old {
 		t.Errorf("recent tight match score = %v, want > old scattered match score %v", recent, old)
 	}
@@ -353,7 +353,7 @@ func TestItemScore_NoRecency(t *testing.T) {
 	config := DefaultConfig()
 	now := int64(1000000)
 	// timestamp=0, frequency=0 → no recency/frecency bonus
-	score := config.ItemScore("test", 50, []int{0}, 0, 0, false, now)
+	score := config.ItemScore("test", 50, []int{0}, 0, 0, false, false, now)
 	// matchScore = (50 + PrefixBonus + WordBoundaryBonus) * MatchWeight
 	expected := (50.0 + config.PrefixBonus + config.WordBoundaryBonus) * config.MatchWeight
 	if score != expected {
@@ -364,18 +364,28 @@ func TestItemScore_NoRecency(t *testing.T) {
 func TestItemScore_CurrentBranchBonus(t *testing.T) {
 	config := DefaultConfig()
 	now := int64(1000000)
-	scoreWith := config.ItemScore("main", 50, []int{}, 0, 0, true, now)
-	scoreWithout := config.ItemScore("main", 50, []int{}, 0, 0, false, now)
+	scoreWith := config.ItemScore("main", 50, []int{}, 0, 0, true, false, now)
+	scoreWithout := config.ItemScore("main", 50, []int{}, 0, 0, false, false, now)
 	if scoreWith-scoreWithout != config.CurrentBranchBonus {
 		t.Errorf("CurrentBranchBonus diff = %v, want %v", scoreWith-scoreWithout, config.CurrentBranchBonus)
 	}
 }
 
+func TestItemScore_DirectoryBonus(t *testing.T) {
+	config := DefaultConfig()
+	now := int64(1000000)
+	scoreWith := config.ItemScore("make test", 50, []int{0}, now-60, 3, false, true, now)
+	scoreWithout := config.ItemScore("make test", 50, []int{0}, now-60, 3, false, false, now)
+	if scoreWith-scoreWithout != config.DirectoryBonus {
+		t.Errorf("DirectoryBonus diff = %v, want %v", scoreWith-scoreWithout, config.DirectoryBonus)
+	}
+}
+
 func TestItemScore_ZeroFuzzyScore(t *testing.T) {
 	config := DefaultConfig()
 	now := int64(1000000)
 	// fuzzyScore=0, no matched indexes, no timestamp → score should be 0
-	score := config.ItemScore("test", 0, []int{}, 0, 0, false, now)
+	score := config.ItemScore("test", 0, []int{}, 0, 0, false, false, now)
 	if score != 0 {
 		t.Errorf("ItemScore with zero fuzzy and no bonuses = %v, want 0", score)
 	}
@@ -1,7 +1,9 @@
 package ui
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -26,10 +28,10 @@ func IsBinary(content []byte) bool {
 	return false
 }
 
-// previewLine expands tabs and cuts a line to width display cells, leaving ANSI
+// PreviewLine expands tabs and cuts a line to width display cells, leaving ANSI
 // sequences intact. Tabs must be expanded first: the terminal renders them as
 // several cells, so measuring them as one would let the line wrap anyway.
-func previewLine(line string, width int) string {
+func PreviewLine(line string, width int) string {
 	line = strings.ReplaceAll(line, "\t", tabIndent)
 	if width <= 0 {
 		return line
@@ -37,11 +39,43 @@ func previewLine(line string, width int) string {
 	return ansi.Truncate(line, width, "…")
 }
 
+// readHead reads the first maxLines lines of the file at path, and at most
+// MaxPreviewBytes, so previewing a huge file costs no more than a small one.
+// At least BinaryDetectionBytes are read when available, for IsBinary.
+func readHead(path string, maxLines int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	r := io.LimitReader(f, MaxPreviewBytes)
+	buf := make([]byte, 0, BinaryDetectionBytes)
+	chunk := make([]byte, BinaryDetectionBytes)
+	lines := 0
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		lines += bytes.Count(chunk[:n], []byte{'\n'})
+		if lines >= maxLines && len(buf) >= BinaryDetectionBytes {
+			return buf, nil
+		}
+		if err == io.EOF {
+			return buf, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
 // GetFilePreview returns a preview of the file contents with syntax highlighting.
 // maxWidth is the display width of the preview pane: lines wider than that are
 // truncated so they do not wrap and grow the pane beyond its height.
 func GetFilePreview(path string, maxLines, maxWidth int) string {
-	content, err := os.ReadFile(path)
+	content, err := readHead(path, maxLines)
 	if err != nil {
 		return ""
 	}
@@ -67,7 +101,7 @@ func GetFilePreview(path string, maxLines, maxWidth int) string {
 		sb.Grow(len(lines) * (maxWidth + 4))
 		for _, line := range lines {
 			sb.WriteString("  ")
-			sb.WriteString(previewLine(line, contentWidth))
+			sb.WriteString(PreviewLine(line, contentWidth))
 			sb.WriteByte('\n')
 		}
 
@@ -83,7 +117,7 @@ func GetFilePreview(path string, maxLines, maxWidth int) string {
 	var sb strings.Builder
 	sb.Grow(len(lines) * (maxWidth + 4))
 	for _, line := range lines {
-		line = previewLine(line, contentWidth)
+		line = PreviewLine(line, contentWidth)
 		sb.WriteString(InactiveContextStyle.Render(fmt.Sprintf("  %s", line)))
 		sb.WriteByte('\n')
 	}
@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadHead_StopsAfterNeededLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.log")
+	line := strings.Repeat("x", 99) + "\n"
+	if err := os.WriteFile(path, []byte(strings.Repeat(line, 10000)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readHead(path, 5)
+	if err != nil {
+		t.Fatalf("readHead() returned unexpected error: %v", err)
+	}
+	if len(got) < 5*len(line) {
+		t.Errorf("readHead() read %d bytes, want at least 5 lines", len(got))
+	}
+	if len(got) > 2*BinaryDetectionBytes {
+		t.Errorf("readHead() read %d bytes of a %d byte file for 5 lines", len(got), 10000*len(line))
+	}
+}
+
+func TestReadHead_CapsLongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "min.js")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 2*MaxPreviewBytes)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readHead(path, 5)
+	if err != nil {
+		t.Fatalf("readHead() returned unexpected error: %v", err)
+	}
+	if len(got) != MaxPreviewBytes {
+		t.Errorf("readHead() read %d bytes, want MaxPreviewBytes (%d)", len(got), MaxPreviewBytes)
+	}
+}
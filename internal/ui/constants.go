@@ -8,9 +8,23 @@ const (
 	// MaxPreviewLines is the maximum number of lines to show in file preview
 	MaxPreviewLines = 50
 
+	// MaxPreviewBytes is the maximum number of bytes read from a file for its
+	// preview, which bounds the cost of files with very long lines
+	MaxPreviewBytes = 256 * 1024
+
+	// MaxDiffLines is the maximum number of diff lines rendered in a preview
+	MaxDiffLines = 1000
+
+	// MaxWorktreeChanges is the maximum number of changed files listed in a
+	// worktree preview
+	MaxWorktreeChanges = 15
+
 	// MaxDirectoryEntries is the maximum number of directory entries to show
 	MaxDirectoryEntries = 20
+)
 
+// History preview settings, which can be changed in the config file
+var (
 	// HistoryContextLinesBefore is the number of history context lines before
 	HistoryContextLinesBefore = 3
 
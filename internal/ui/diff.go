@@ -0,0 +1,29 @@
+package ui
+
+import "strings"
+
+// GetDiffPreview renders a unified diff (e.g. `git show` output) with syntax
+// highlighting. Only the first maxLines lines are highlighted, since a large
+// patch would otherwise be tokenised in full for a pane that shows a screenful;
+// lines wider than maxWidth are truncated so they do not wrap.
+func GetDiffPreview(diff string, maxLines, maxWidth int) string {
+	lines := strings.SplitN(diff, "\n", maxLines+1)
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+	diff = strings.Join(lines, "\n")
+
+	// The .diff extension selects chroma's diff lexer.
+	highlighted, err := HighlightCode(diff, "preview.diff")
+	if err != nil || highlighted == "" {
+		highlighted = diff
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(highlighted) + len(lines)*2)
+	for _, line := range strings.Split(strings.TrimRight(highlighted, "\n"), "\n") {
+		sb.WriteString(PreviewLine(line, maxWidth))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -2,9 +2,20 @@ package ui
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 )
 
+// FormatDir abbreviates a directory path by replacing the home directory with ~
+func FormatDir(path string) string {
+	home, err := os.UserHomeDir()
+	if err == nil {
+		path = strings.Replace(path, home, "~", 1)
+	}
+	return path
+}
+
 // FormatFileSize formats a file size in bytes to a human-readable string
 func FormatFileSize(size int64) string {
 	const (
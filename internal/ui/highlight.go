@@ -44,29 +44,40 @@ var supportedLanguages = map[string]bool{
 	"JSON":       true,
 	"Rust":       true,
 	"YAML":       true,
+	"Diff":       true,
 }
 
-// chromaFormatter and chromaStyle are cached to avoid per-call lookup
+// chromaFormatter and chromaStyle are cached to avoid per-call lookup, and
+// set by SetTheme
 var (
-	chromaFormatter = func() chroma.Formatter {
-		f := formatters.Get("terminal256")
-		if f == nil {
-			return formatters.Fallback
-		}
-		return f
-	}()
-
-	chromaStyle = func() *chroma.Style {
-		s := styles.Get("tokyonight")
-		if s == nil {
-			return styles.Fallback
-		}
-		return s
-	}()
+	chromaFormatter chroma.Formatter
+	chromaStyle     *chroma.Style
 )
 
+func init() {
+	setChromaStyle(currentTheme)
+}
+
+// setChromaStyle highlights code with the chroma style of t, in the 16 colors
+// of the terminal for ANSI themes.
+func setChromaStyle(t Theme) {
+	formatter := "terminal256"
+	if t.ANSI {
+		formatter = "terminal16"
+	}
+	chromaFormatter = formatters.Get(formatter)
+	if chromaFormatter == nil {
+		chromaFormatter = formatters.Fallback
+	}
+	chromaStyle = styles.Get(t.Chroma)
+}
+
 // HighlightCode performs syntax highlighting on code
 func HighlightCode(code string, filename string) (string, error) {
+	if Monochrome {
+		return code, nil
+	}
+
 	// Determine lexer from filename
 	lexer := lexers.Match(filename)
 	if lexer == nil {
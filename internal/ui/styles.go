@@ -2,42 +2,91 @@ package ui
 
 import "charm.land/lipgloss/v2"
 
-// Tokyo Night color palette
-const (
-	ColorCyan        = "#7dcfff"
-	ColorPurple      = "#bb9af7"
-	ColorForeground  = "#c0caf5"
-	ColorYellow      = "#e0af68"
-	ColorOrange      = "#ff9e64"
-	ColorComment     = "#9aa5ce"
-	ColorBlue        = "#7aa2f7"
-	ColorPink        = "#f7768e" // Tokyo Night pink/magenta for highlights
-	ColorSelectionBg = "#414868" // Tokyo Night selection color for better visibility
-	ColorBorder      = "#565f89" // Tokyo Night gray for borders
-	ColorTimeAgo     = "#7aa2f7" // Blue-ish gray for time ago display
-)
+// Palette holds the colors of the interface. Each is a hex code ("#7dcfff")
+// or an ANSI color number ("6").
+type Palette struct {
+	Cyan        string `toml:"cyan"`
+	Purple      string `toml:"purple"`
+	Foreground  string `toml:"foreground"`
+	Yellow      string `toml:"yellow"`
+	Orange      string `toml:"orange"`
+	Comment     string `toml:"comment"`
+	Blue        string `toml:"blue"`
+	Pink        string `toml:"pink"`         // Highlights
+	SelectionBg string `toml:"selection_bg"` // Background of the selected line
+	Border      string `toml:"border"`
+	TimeAgo     string `toml:"time_ago"`
+}
+
+// TokyoNight is the default palette
+var TokyoNight = Palette{
+	Cyan:        "#7dcfff",
+	Purple:      "#bb9af7",
+	Foreground:  "#c0caf5",
+	Yellow:      "#e0af68",
+	Orange:      "#ff9e64",
+	Comment:     "#9aa5ce",
+	Blue:        "#7aa2f7",
+	Pink:        "#f7768e", // Tokyo Night pink/magenta for highlights
+	SelectionBg: "#414868", // Tokyo Night selection color for better visibility
+	Border:      "#565f89", // Tokyo Night gray for borders
+	TimeAgo:     "#7aa2f7", // Blue-ish gray for time ago display
+}
+
+// Colors is the palette in use, set with SetTheme
+var Colors = TokyoNight
 
-// Styles for preview window and TUI elements
+// Styles for preview window and TUI elements, built from Colors by
+// buildStyles
 var (
+	HeaderStyle          lipgloss.Style
+	LabelStyle           lipgloss.Style
+	ContentStyle         lipgloss.Style
+	ContextHeaderStyle   lipgloss.Style
+	ActiveContextStyle   lipgloss.Style
+	InactiveContextStyle lipgloss.Style
+	MatchStyle           lipgloss.Style
+)
+
+func init() {
+	buildStyles()
+}
+
+func buildStyles() {
+	if Monochrome {
+		HeaderStyle = lipgloss.NewStyle().Bold(true)
+		LabelStyle = lipgloss.NewStyle()
+		ContentStyle = lipgloss.NewStyle()
+		ContextHeaderStyle = lipgloss.NewStyle().Bold(true)
+		ActiveContextStyle = lipgloss.NewStyle().Reverse(true)
+		InactiveContextStyle = lipgloss.NewStyle()
+		MatchStyle = lipgloss.NewStyle().Bold(true)
+		return
+	}
+
 	HeaderStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ColorCyan)).
-			Bold(true).
-			Underline(true)
+		Foreground(lipgloss.Color(Colors.Cyan)).
+		Bold(true).
+		Underline(true)
 
 	LabelStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ColorPurple))
+		Foreground(lipgloss.Color(Colors.Purple))
 
 	ContentStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ColorForeground))
+		Foreground(lipgloss.Color(Colors.Foreground))
 
 	ContextHeaderStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ColorYellow)).
-				Bold(true)
+		Foreground(lipgloss.Color(Colors.Yellow)).
+		Bold(true)
 
 	ActiveContextStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ColorOrange)).
-				Bold(true)
+		Foreground(lipgloss.Color(Colors.Orange)).
+		Bold(true)
 
 	InactiveContextStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ColorComment))
-)
+		Foreground(lipgloss.Color(Colors.Comment))
+
+	MatchStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(Colors.Pink)).
+		Bold(true)
+}
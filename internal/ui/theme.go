@@ -0,0 +1,162 @@
+package ui
+
+import (
+	"sort"
+
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Theme is a palette for the interface together with the chroma style that
+// highlights code in previews
+type Theme struct {
+	Palette
+	Chroma string `toml:"chroma"` // Name of a chroma style, e.g. "monokai"
+	// ANSI themes use the 16 colors of the terminal's own palette, so code is
+	// highlighted with those too.
+	ANSI bool `toml:"ansi"`
+}
+
+// Themes are the built-in themes, by name
+var Themes = map[string]Theme{
+	"tokyonight": {Palette: TokyoNight, Chroma: "tokyonight"},
+	"tokyonight-day": {
+		Palette: Palette{
+			Cyan:        "#007197",
+			Purple:      "#9854f1",
+			Foreground:  "#3760bf",
+			Yellow:      "#8c6c3e",
+			Orange:      "#b15c00",
+			Comment:     "#848cb5",
+			Blue:        "#2e7de9",
+			Pink:        "#f52a65",
+			SelectionBg: "#b7c1e3",
+			Border:      "#a8aecb",
+			TimeAgo:     "#2e7de9",
+		},
+		Chroma: "tokyonight-day",
+	},
+	"dracula": {
+		Palette: Palette{
+			Cyan:        "#8be9fd",
+			Purple:      "#bd93f9",
+			Foreground:  "#f8f8f2",
+			Yellow:      "#f1fa8c",
+			Orange:      "#ffb86c",
+			Comment:     "#6272a4",
+			Blue:        "#8be9fd",
+			Pink:        "#ff79c6",
+			SelectionBg: "#44475a",
+			Border:      "#6272a4",
+			TimeAgo:     "#bd93f9",
+		},
+		Chroma: "dracula",
+	},
+	"gruvbox-dark": {
+		Palette: Palette{
+			Cyan:        "#8ec07c",
+			Purple:      "#d3869b",
+			Foreground:  "#ebdbb2",
+			Yellow:      "#fabd2f",
+			Orange:      "#fe8019",
+			Comment:     "#a89984",
+			Blue:        "#83a598",
+			Pink:        "#fb4934",
+			SelectionBg: "#504945",
+			Border:      "#665c54",
+			TimeAgo:     "#83a598",
+		},
+		Chroma: "gruvbox",
+	},
+	"gruvbox-light": {
+		Palette: Palette{
+			Cyan:        "#427b58",
+			Purple:      "#8f3f71",
+			Foreground:  "#3c3836",
+			Yellow:      "#b57614",
+			Orange:      "#af3a03",
+			Comment:     "#7c6f64",
+			Blue:        "#076678",
+			Pink:        "#9d0006",
+			SelectionBg: "#d5c4a1",
+			Border:      "#bdae93",
+			TimeAgo:     "#076678",
+		},
+		Chroma: "gruvbox-light",
+	},
+	"solarized-light": {
+		Palette: Palette{
+			Cyan:        "#2aa198",
+			Purple:      "#6c71c4",
+			Foreground:  "#586e75",
+			Yellow:      "#b58900",
+			Orange:      "#cb4b16",
+			Comment:     "#93a1a1",
+			Blue:        "#268bd2",
+			Pink:        "#d33682",
+			SelectionBg: "#eee8d5",
+			Border:      "#93a1a1",
+			TimeAgo:     "#268bd2",
+		},
+		Chroma: "solarized-light",
+	},
+	// The terminal's default foreground and its 16 colors, so fuzz.fish
+	// follows the terminal's color scheme, light or dark.
+	"ansi": {
+		Palette: Palette{
+			Cyan:        "6",
+			Purple:      "5",
+			Foreground:  "",
+			Yellow:      "3",
+			Orange:      "11",
+			Comment:     "8",
+			Blue:        "4",
+			Pink:        "1",
+			SelectionBg: "8",
+			Border:      "8",
+			TimeAgo:     "4",
+		},
+		Chroma: "vim",
+		ANSI:   true,
+	},
+}
+
+// DefaultTheme is the name of the theme used unless another is chosen
+const DefaultTheme = "tokyonight"
+
+// ThemeNames returns the names of the built-in themes, sorted.
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasChromaStyle reports whether name is a chroma style.
+func HasChromaStyle(name string) bool {
+	_, ok := styles.Registry[name]
+	return ok
+}
+
+// Monochrome is set when colors are turned off (NO_COLOR or a terminal
+// without colors); the styles then use bold and reverse video only and
+// previews are not highlighted.
+var Monochrome bool
+
+// currentTheme is the theme in use, set with SetTheme
+var currentTheme = Themes[DefaultTheme]
+
+// SetTheme makes t the theme in use and rebuilds the styles from it.
+func SetTheme(t Theme) {
+	currentTheme = t
+	Colors = t.Palette
+	setChromaStyle(t)
+	buildStyles()
+}
+
+// SetMonochrome turns colors off or on again and rebuilds the styles.
+func SetMonochrome(on bool) {
+	Monochrome = on
+	buildStyles()
+}
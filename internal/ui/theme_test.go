@@ -0,0 +1,29 @@
+package ui
+
+import "testing"
+
+func TestThemes_HaveChromaStylesAndColors(t *testing.T) {
+	for _, name := range ThemeNames() {
+		theme := Themes[name]
+		if !HasChromaStyle(theme.Chroma) {
+			t.Errorf("theme %s: unknown chroma style %q", name, theme.Chroma)
+		}
+		if theme.Pink == "" || theme.SelectionBg == "" || theme.Border == "" {
+			t.Errorf("theme %s: highlight, selection or border color missing", name)
+		}
+	}
+	if _, ok := Themes[DefaultTheme]; !ok {
+		t.Errorf("DefaultTheme %q is not a theme", DefaultTheme)
+	}
+}
+
+func TestHighlightCode_Monochrome(t *testing.T) {
+	SetMonochrome(true)
+	t.Cleanup(func() { SetMonochrome(false) })
+
+	code := "package main\n\nfunc main() {}\n"
+	got, err := HighlightCode(code, "main.go")
+	if err != nil || got != code {
+		t.Errorf("HighlightCode() = %q, %v; want the code unchanged", got, err)
+	}
+}